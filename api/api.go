@@ -1,6 +1,11 @@
 package api
 
-import "github.com/ohait/forego/ctx"
+import (
+	"errors"
+	"reflect"
+
+	"github.com/ohait/forego/ctx"
+)
 
 type Op interface {
 	Do(c ctx.C) error
@@ -9,3 +14,50 @@ type Op interface {
 type StreamingOp interface {
 	Stream(c ctx.C, emit func(ctx.C, any) error) error
 }
+
+// call op.Do() in a span named after the type of op, used by the servers
+func Do(c ctx.C, op Op) error {
+	c, cf := ctx.Span(c, "api "+opName(op))
+	err := op.Do(c)
+	cf(err)
+	return err
+}
+
+// call op.Stream() in a span named after the type of op, used by the servers
+func Stream(c ctx.C, op StreamingOp, emit func(ctx.C, any) error) error {
+	c, cf := ctx.Span(c, "api "+opName(op))
+	err := op.Stream(c, emit)
+	cf(err)
+	return err
+}
+
+func opName(op any) string {
+	t := reflect.TypeOf(op)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.String()
+}
+
+// Ops can implement this to allow caching of GET responses, it's called after Do()
+// and both values are optional, e.g. `return "public, max-age=60", this.Version`
+type Cacheable interface {
+	CacheControl(c ctx.C) (cacheControl string, etag string)
+}
+
+// Ops can implement this to be also served with GET and HEAD, reading the `in` fields from the query string (they must all
+// be scalars, see Handler.Queryable()). Only for ops without side effects: GET can be triggered by links, prefetchers and caches.
+// It's the only rule, for StreamingOps too (e.g. Server-Sent Events), and also for ops without `in` fields: having no input
+// doesn't mean having no side effects, so GET is never implied
+type Safe interface {
+	Safe() bool
+}
+
+// StreamingOps can implement this to be resumed by Server-Sent Events clients reconnecting with a `Last-Event-ID`:
+// lastID is the id of the last event received, the op must skip what was already sent (ids start from 1)
+type Resumable interface {
+	Resume(c ctx.C, lastID int) error
+}
+
+// returned (wrapped) by ServerRequest.Auth() when a field is `auth,required` but no identity was provided
+var ErrAuthRequired = errors.New("auth required")
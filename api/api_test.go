@@ -1,10 +1,14 @@
 package api_test
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
 	"strings"
 	"testing"
 
 	"github.com/ohait/forego/api"
+	"github.com/ohait/forego/api/openapi"
 	"github.com/ohait/forego/ctx"
 	"github.com/ohait/forego/enc"
 	"github.com/ohait/forego/test"
@@ -94,3 +98,140 @@ func (this *WordCount) Do(ctx.C) error {
 	this.Ct = len(strings.Fields(this.Str))
 	return nil
 }
+
+type Item struct {
+	ID     int      `api:"path,id"`
+	Cursor string   `api:"query,cursor"`
+	Tags   []string `api:"query,tag"`
+	RID    string   `api:"header,X-Request-Id,required"`
+
+	Name string `api:"out" json:"name"`
+}
+
+func (this *Item) Do(ctx.C) error {
+	this.Name = fmt.Sprintf("item %d after %q %v (%s)", this.ID, this.Cursor, this.Tags, this.RID)
+	return nil
+}
+
+func TestParams(t *testing.T) {
+	c := test.Context(t)
+
+	h, err := api.NewHandler(c, &Item{})
+	test.NoError(t, err)
+
+	data := &api.JSON{}
+	err = h.Client().Send(c, &Item{ID: 42, Cursor: "abc", Tags: []string{"x", "y"}, RID: "r1"}, data)
+	test.NoError(t, err)
+	test.EqualsGo(t, "42", data.Path["id"])
+	test.EqualsGo(t, "abc", data.Query.Get("cursor"))
+	test.EqualsGo(t, []string{"x", "y"}, data.Query["tag"])
+	test.EqualsGo(t, "r1", data.Header.Get("X-Request-Id"))
+
+	obj, err := h.Server().Recv(c, data)
+	test.NoError(t, err)
+	test.EqualsGo(t, 42, obj.ID)
+	test.EqualsGo(t, "abc", obj.Cursor)
+	test.EqualsGo(t, []string{"x", "y"}, obj.Tags)
+	test.EqualsGo(t, "r1", obj.RID)
+
+	data.Header = nil
+	_, err = h.Server().Recv(c, data)
+	test.Error(t, err) // header is required
+
+	o := openapi.NewService("test")
+	pi, err := h.UpdateOpenAPI(c, o, "/items/{id}")
+	test.NoError(t, err)
+	test.EqualsGo(t, 4, len(pi.Parameters))
+	test.EqualsGo(t, "id", pi.Parameters[0].Name)
+	test.EqualsGo(t, "path", pi.Parameters[0].In)
+	test.Assert(t, pi.Parameters[0].Required)
+	test.EqualsGo(t, "X-Request-Id", pi.Parameters[3].Name)
+	test.EqualsGo(t, "header", pi.Parameters[3].In)
+	test.Assert(t, pi.Parameters[3].Required)
+	test.Assert(t, !pi.Parameters[1].Required)
+}
+
+type Search struct {
+	Query string   `api:"in,required" json:"query" validate:"minlen=2,maxlen=10,pattern=^[a-z]{2,}$"`
+	Size  int      `api:"in" json:"size" validate:"min=1,max=100"`
+	Sort  string   `api:"in" json:"sort" validate:"enum=asc|desc"`
+	Tags  []string `api:"in" json:"tags" validate:"maxlen=2,pattern=^#"`
+	Range *Range   `api:"in" json:"range"`
+	Page  int      `api:"query,page" validate:"max=10"`
+
+	Ct int `api:"out" json:"ct"`
+}
+
+type Range struct {
+	From int `json:"from" validate:"min=0"`
+	To   int `json:"to" validate:"max=99"`
+}
+
+func (this *Search) Do(ctx.C) error {
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	c := test.Context(t)
+
+	h, err := api.NewHandler(c, &Search{})
+	test.NoError(t, err)
+
+	recv := func(data enc.Map) error {
+		t.Helper()
+		_, err := h.Server().Recv(c, &api.JSON{Data: data, Query: url.Values{"page": {"11"}}})
+		return err
+	}
+
+	err = recv(enc.Map{"query": enc.String("foo"), "size": enc.Integer(10)})
+	var invalid api.ValidationError
+	test.Assert(t, errors.As(err, &invalid))
+	test.EqualsGo(t, []api.FieldError{{Path: "page", Message: "must be at most 10"}}, invalid.Fields)
+
+	err = recv(enc.Map{
+		"query": enc.String("Foo123456789"),
+		"size":  enc.Integer(0), // zero values are checked if sent
+		"sort":  enc.String(""),
+		"tags":  enc.List{enc.String("#a"), enc.String("b"), enc.String("#c")},
+		"range": enc.Map{"from": enc.Integer(-1), "to": enc.Integer(100)},
+	})
+	test.Assert(t, errors.As(err, &invalid))
+	t.Logf("err: %v", err)
+	test.EqualsGo(t, []api.FieldError{
+		{Path: "query", Message: "must be at most 10 characters"},
+		{Path: "query", Message: `must match "^[a-z]{2,}$"`},
+		{Path: "size", Message: "must be at least 1"},
+		{Path: "sort", Message: "must be one of asc|desc"},
+		{Path: "tags", Message: "must have at most 2 items"},
+		{Path: "tags[1]", Message: `must match "^#"`},
+		{Path: "range.from", Message: "must be at least 0"},
+		{Path: "range.to", Message: "must be at most 99"},
+		{Path: "page", Message: "must be at most 10"},
+	}, invalid.Fields)
+
+	err = recv(enc.Map{})
+	test.Assert(t, errors.As(err, &invalid))
+	test.EqualsGo(t, "query", invalid.Fields[0].Path)
+	test.EqualsGo(t, "required", invalid.Fields[0].Message)
+
+	type List struct {
+		Items []string `api:"in" json:"items" validate:"minlen=1"`
+		Limit *int     `api:"in" json:"limit" validate:"min=1"`
+	}
+	hl, err := api.NewHandler(c, &List{})
+	test.NoError(t, err)
+	_, err = hl.Server().Recv(c, &api.JSON{Data: enc.Map{}}) // missing, not checked
+	test.NoError(t, err)
+	_, err = hl.Server().Recv(c, &api.JSON{Data: enc.Map{"items": enc.List{}, "limit": enc.Integer(0)}})
+	test.Assert(t, errors.As(err, &invalid))
+	test.EqualsGo(t, []api.FieldError{
+		{Path: "items", Message: "must have at least 1 items"},
+		{Path: "limit", Message: "must be at least 1"},
+	}, invalid.Fields)
+
+	type Bad struct {
+		Flag bool `api:"in" validate:"min=1"`
+	}
+	_, err = api.NewHandler(c, &Bad{})
+	test.Error(t, err)
+}
@@ -9,6 +9,8 @@ import (
 // the client request object used to Marshal a request to a server
 type ClientRequest interface {
 	Marshal(c ctx.C, name string, into reflect.Value) error
+	// marshal a field bound to a header, query or path parameter
+	MarshalParam(c ctx.C, source, name string, from reflect.Value) error
 }
 
 // the client response object used to unmarshal the response from the server
@@ -19,9 +21,26 @@ type ClientResponse interface {
 // the server request object used to Unmarshal the request from the client
 type ServerRequest interface {
 	Unmarshal(c ctx.C, name string, into reflect.Value) error
+	// unmarshal a field bound to a header, query or path parameter, leave it untouched if missing
+	UnmarshalParam(c ctx.C, source, name string, into reflect.Value) error
 	Auth(c ctx.C, into reflect.Value, required bool) error
 }
 
+// optionally implemented by a ServerRequest, to tell if a field was sent: source is "" for the body, or
+// InHeader, InQuery or InPath. The `validate` rules are not checked for the missing fields.
+// If not implemented, zero values are considered missing
+type FieldChecker interface {
+	Has(source, name string) bool
+}
+
+// true if the field was sent, see FieldChecker
+func sent(req ServerRequest, source, name string, fv reflect.Value) bool {
+	if fc, ok := req.(FieldChecker); ok {
+		return fc.Has(source, name)
+	}
+	return !fv.IsZero()
+}
+
 // the server response object used to marshal the response to the client
 type ServerResponse interface {
 	Marshal(c ctx.C, name string, into reflect.Value) error
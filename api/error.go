@@ -0,0 +1,151 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ohait/forego/ctx"
+)
+
+// Error can be returned by ops to signal a status and a machine readable code to the client,
+// the Message and the Fields are sent to the client, the Err is only logged
+type Error struct {
+	Status  int          // http status, e.g. 404
+	Code    string       // machine readable, e.g. "item_not_found", defaults to StatusCode(Status)
+	Message string       // human readable
+	Fields  []FieldError // optional, details about specific fields
+	Err     error        // optional cause, never sent to the client
+}
+
+// create a new Error, e.g. `api.NewError(404, "item_not_found", "no item %q", id)`
+func NewError(status int, code string, f string, args ...any) Error {
+	return Error{
+		Status:  status,
+		Code:    code,
+		Message: fmt.Sprintf(f, args...),
+	}
+}
+
+// return a copy of this error with the given cause
+func (this Error) Wrap(err error) Error {
+	this.Err = err
+	return this
+}
+
+func (this Error) Error() string {
+	msg := this.Message
+	if msg == "" {
+		msg = http.StatusText(this.Status)
+	}
+	if this.Err != nil {
+		return fmt.Sprintf("%d %s: %s: %v", this.Status, this.code(), msg, this.Err)
+	}
+	return fmt.Sprintf("%d %s: %s", this.Status, this.code(), msg)
+}
+
+func (this Error) Unwrap() error {
+	return this.Err
+}
+
+// an Error is classified by its status, e.g. `errors.Is(api.NewError(404, ...), ctx.ErrNotFound)`
+func (this Error) Is(target error) bool {
+	return StatusIs(this.Status, target)
+}
+
+func (this Error) code() string {
+	if this.Code != "" {
+		return this.Code
+	}
+	return StatusCode(this.Status)
+}
+
+// derive a machine readable code from an http status, e.g. 404 => "not_found"
+func StatusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return fmt.Sprintf("status_%d", status)
+	}
+	text = strings.ToLower(text)
+	text = strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text)
+	return text
+}
+
+// how the classes of errors in ctx map to statuses, the first status of each class is the one returned by ClassStatus
+// a 503 is ErrRetryable only if the server says so with a Retry-After header (see http), so StatusIs(503, ctx.ErrRetryable) is false
+var classes = []struct {
+	class     error
+	status    int
+	ambiguous bool // not implied by the status, see StatusIs
+}{
+	{ctx.ErrInvalid, 400, false},
+	{ctx.ErrUnauthenticated, 401, false},
+	{ctx.ErrForbidden, 403, false},
+	{ctx.ErrNotFound, 404, false},
+	{ctx.ErrConflict, 409, false},
+	{ctx.ErrUnavailable, 503, false},
+	{ctx.ErrRetryable, 503, true},
+	{ctx.ErrRetryable, 429, false},
+	{ctx.ErrRetryable, 502, false},
+	{ctx.ErrRetryable, 504, false},
+}
+
+// return the status of the first class (e.g. ctx.ErrNotFound => 404) err is classified as, or 0 if none
+func ClassStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	for _, c := range classes {
+		if errors.Is(err, c.class) {
+			return c.status
+		}
+	}
+	return 0
+}
+
+// true if the status belongs to the given class, e.g. `StatusIs(429, ctx.ErrRetryable)`
+func StatusIs(status int, class error) bool {
+	for _, c := range classes {
+		if c.status == status && c.class == class && !c.ambiguous {
+			return true
+		}
+	}
+	return false
+}
+
+// the code used for ValidationError
+const CodeInvalid = "invalid"
+
+// Ops can implement this to document the errors they may return, which are added to the OpenAPI responses
+type ErrorDeclarer interface {
+	Errors() []Error
+}
+
+// the RFC 7807 representation of an error, sent as `application/problem+json`
+type Problem struct {
+	Type     string       `json:"type"`  // always "about:blank", we use Code instead
+	Title    string       `json:"title"` // http.StatusText(Status)
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"` // never set for 5xx
+	Code     string       `json:"code"`
+	Fields   []FieldError `json:"fields,omitempty"`
+	Tracking string       `json:"tracking,omitempty"`
+
+	// the cause, with the remote stack and tags, only sent by servers which opt in (see http.ExposeErrors)
+	Error *ctx.Error `json:"error,omitempty"`
+}
+
+// convert the problem back into an Error, with the remote cause if any (see ctx.RemoteError)
+func (this Problem) AsError() Error {
+	out := Error{
+		Status:  this.Status,
+		Code:    this.Code,
+		Message: this.Detail,
+		Fields:  this.Fields,
+	}
+	if this.Error != nil {
+		out.Err = *this.Error
+	}
+	return out
+}
@@ -1,7 +1,12 @@
 package api
 
 import (
+	"net/http"
 	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ohait/forego/api/openapi"
 	"github.com/ohait/forego/ctx"
@@ -12,10 +17,15 @@ import (
 type Handler[T any] struct {
 	typ reflect.Type
 
-	auth *field
-	init map[int]reflect.Value
-	in   []field
-	out  []field
+	auth   *field
+	init   map[int]reflect.Value
+	in     []field
+	out    []field
+	params []field // bound to headers, query or path
+
+	queryable bool // all the `in` fields can be read from a query string
+	safe      bool // implements Safe, returning true
+	valid     validator
 }
 
 // helper for NewHandler().Server()
@@ -65,13 +75,22 @@ func NewHandler[T any](c ctx.C, init T) (Handler[T], error) {
 			}
 			this.auth = &f
 		}
+		if tag.in || tag.source != "" {
+			_, err := this.valid.compile(c, ft.Type, tag.validate)
+			if err != nil {
+				return this, ctx.NewErrorf(c, "%T.%s: %w", init, ft.Name, err)
+			}
+		}
 		if tag.in {
 			this.in = append(this.in, f)
 		}
 		if tag.out {
 			this.out = append(this.out, f)
 		}
-		if !tag.in && !tag.out {
+		if tag.source != "" {
+			this.params = append(this.params, f)
+		}
+		if !tag.in && !tag.out && tag.source == "" {
 			v := initV.Field(f.i)
 			if !v.IsZero() {
 				this.init[f.i] = v
@@ -80,9 +99,69 @@ func NewHandler[T any](c ctx.C, init T) (Handler[T], error) {
 		}
 	}
 
+	this.queryable = true
+	for _, f := range this.in {
+		if !isScalar(this.typ.Field(f.i).Type) {
+			this.queryable = false
+		}
+	}
+	if op, ok := reflect.New(this.typ).Interface().(Safe); ok {
+		this.safe = op.Safe()
+	}
+
 	return this, nil
 }
 
+var timeType = reflect.TypeOf(time.Time{})
+
+// true for types which can be represented as a query parameter, or a list of them
+func isScalar(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		t = t.Elem()
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return t == timeType
+	}
+}
+
+// returns true if all the `in` fields can be read from a query string (see Server.RecvQuery())
+func (this *Handler[T]) Queryable() bool {
+	return this.queryable
+}
+
+// returns true if the op implements Safe (returning true), so it can be called with GET
+func (this *Handler[T]) IsSafe() bool {
+	return this.safe
+}
+
+// returns true if the op can be called with GET reading the `in` fields from the query string: it's Queryable() and IsSafe()
+func (this *Handler[T]) AllowGet() bool {
+	return this.queryable && this.safe
+}
+
+// returns the names of the fields bound to the given source (InHeader, InQuery or InPath)
+func (this *Handler[T]) Params(source string) []string {
+	var out []string
+	for _, f := range this.params {
+		if f.tag.source == source {
+			out = append(out, f.tag.param)
+		}
+	}
+	return out
+}
+
 func (this *Handler[T]) Type() reflect.Type {
 	return this.typ
 }
@@ -117,6 +196,21 @@ func (this *Handler[T]) UpdateOpenAPI(c ctx.C, o *openapi.Service, path string)
 		}
 		out.Properties[f.tag.name] = s
 	}
+	var params []openapi.Parameter
+	for _, f := range this.params {
+		ft := this.typ.Field(f.i)
+		s, err := o.SchemaFromType(c, ft.Type, &ft.Tag)
+		if err != nil {
+			return nil, ctx.NewErrorf(c, "%s[%q]: %w", f.tag.source, f.tag.param, err)
+		}
+		params = append(params, openapi.Parameter{
+			Name:        f.tag.param,
+			In:          f.tag.source,
+			Description: ft.Tag.Get("doc"),
+			Required:    f.tag.required || f.tag.source == InPath,
+			Schema:      s,
+		})
+	}
 
 	pi := &openapi.PathItem{
 		Summary: this.typ.PkgPath() + "." + this.typ.Name(),
@@ -136,18 +230,75 @@ func (this *Handler[T]) UpdateOpenAPI(c ctx.C, o *openapi.Service, path string)
 				},
 			},
 		},
+		Parameters: params,
 	}
 	if this.auth != nil {
 		pi.SetJWT(this.auth.tag.required)
 	}
+	err := this.errorResponses(c, o, pi)
+	if err != nil {
+		return nil, err
+	}
 
-	o.Paths[path] = &openapi.Path{
+	p := &openapi.Path{
 		Post: pi,
 	}
-	// NOTE(oha): should we add GET if len(this.in) == 0?
+	if this.AllowGet() {
+		get := pi.Clone()
+		get.RequestBody = nil
+		for _, f := range this.in {
+			get.Parameters = append(get.Parameters, openapi.Parameter{
+				Name:        f.tag.name,
+				In:          InQuery,
+				Description: in.Properties[f.tag.name].Description,
+				Required:    f.tag.required,
+				Schema:      in.Properties[f.tag.name],
+			})
+		}
+		p.Get = get
+	}
+	o.Paths[path] = p
 	return pi, nil
 }
 
+// add the 4xx responses: 400 if there is any input, 401 if auth is required, and the ones declared by the op
+func (this *Handler[T]) errorResponses(c ctx.C, o *openapi.Service, pi *openapi.PathItem) error {
+	var errs []Error
+	if len(this.in)+len(this.params) > 0 {
+		errs = append(errs, Error{Status: 400, Code: CodeInvalid})
+	}
+	if this.auth != nil && this.auth.tag.required {
+		errs = append(errs, Error{Status: 401})
+	}
+	if d, ok := reflect.New(this.typ).Interface().(ErrorDeclarer); ok {
+		errs = append(errs, d.Errors()...)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	s, err := o.SchemaFromType(c, reflect.TypeOf(Problem{}), nil)
+	if err != nil {
+		return ctx.NewErrorf(c, "problem: %w", err)
+	}
+	codes := map[int][]string{}
+	for _, e := range errs {
+		if !slices.Contains(codes[e.Status], e.code()) {
+			codes[e.Status] = append(codes[e.Status], e.code())
+		}
+	}
+	for status, list := range codes {
+		pi.Responses[strconv.Itoa(status)] = openapi.Response{
+			Description: http.StatusText(status) + ": " + strings.Join(list, ", "),
+			Content: map[string]openapi.Content{
+				"application/problem+json": {
+					Schema: s,
+				},
+			},
+		}
+	}
+	return nil
+}
+
 func (this *Handler[T]) Server() Server[T] {
 	return Server[T]{*this}
 }
@@ -178,26 +329,72 @@ func (this Client[T]) Send(c ctx.C, obj T, data ClientRequest) error {
 			return ctx.NewErrorf(c, "can't SendRequest %T.%s: %w", obj, f.tag.name, err)
 		}
 	}
+	for _, f := range this.params {
+		fv := v.Field(f.i)
+		err := data.MarshalParam(c, f.tag.source, f.tag.param, fv)
+		if err != nil {
+			return ctx.NewErrorf(c, "can't SendRequest %T %s %q: %w", obj, f.tag.source, f.tag.param, err)
+		}
+	}
 	return nil
 }
 
 func (this Server[T]) Recv(c ctx.C, req ServerRequest) (T, error) {
+	return this.recv(c, req, false)
+}
+
+// like Recv(), but the `in` fields are read from the query parameters instead of the body
+// fails if the handler is not Queryable()
+func (this Server[T]) RecvQuery(c ctx.C, req ServerRequest) (T, error) {
+	if !this.queryable {
+		var zero T
+		return zero, ctx.NewErrorf(c, "%v can't be read from a query string", this.typ)
+	}
+	return this.recv(c, req, true)
+}
+
+func (this Server[T]) recv(c ctx.C, req ServerRequest, query bool) (T, error) {
 	var zero T
-	log.Debugf(c, "Server[%T].Recv(%+v)", zero, req)
 	ptrV := reflect.New(this.typ)
 	v := ptrV.Elem()
 	for i, fv := range this.init {
 		v.Field(i).Set(fv)
 		//log.Debugf(c, "init %T.%v = %#v", this.typ, this.typ.Field(i).Name, fv)
 	}
+	var invalid []FieldError
 	for _, f := range this.in {
 		fv := v.Field(f.i)
-		err := req.Unmarshal(c, f.tag.name, fv)
+		var err error
+		source := ""
+		if query {
+			source = InQuery
+			err = req.UnmarshalParam(c, InQuery, f.tag.name, fv)
+		} else {
+			err = req.Unmarshal(c, f.tag.name, fv)
+		}
 		if err != nil {
 			return zero, ctx.NewErrorf(c, "can't RecvRequest %T.%s: %w", zero, f.tag.name, err)
 		}
 		if f.tag.required && fv.IsZero() {
-			return zero, ctx.NewErrorf(c, "missing field %q in request", f.tag.name)
+			invalid = append(invalid, FieldError{Path: f.tag.name, Message: "required"})
+			continue
+		}
+		if sent(req, source, f.tag.name, fv) {
+			this.valid.validate(f.tag.name, fv, f.tag.validate, &invalid)
+		}
+	}
+	for _, f := range this.params {
+		fv := v.Field(f.i)
+		err := req.UnmarshalParam(c, f.tag.source, f.tag.param, fv)
+		if err != nil {
+			return zero, ctx.NewErrorf(c, "can't RecvRequest %T %s %q: %w", zero, f.tag.source, f.tag.param, err)
+		}
+		if f.tag.required && fv.IsZero() {
+			invalid = append(invalid, FieldError{Path: f.tag.param, Message: "required"})
+			continue
+		}
+		if sent(req, f.tag.source, f.tag.param, fv) {
+			this.valid.validate(f.tag.param, fv, f.tag.validate, &invalid)
 		}
 	}
 	if this.auth != nil {
@@ -207,7 +404,12 @@ func (this Server[T]) Recv(c ctx.C, req ServerRequest) (T, error) {
 			return zero, ctx.NewErrorf(c, "can't RecvRequest %T Auth(): %w", zero, err)
 		}
 	}
-	return v.Addr().Interface().(T), nil
+	if len(invalid) > 0 {
+		return zero, ctx.WrapError(c, ValidationError{Fields: invalid})
+	}
+	obj := v.Addr().Interface().(T)
+	log.Debug(c, "Server.Recv", log.F("type", this.typ.String()), log.F("in", this.LogIn(obj)))
+	return obj, nil
 }
 
 func (this Server[T]) Send(c ctx.C, obj T, res ServerResponse) (err error) {
@@ -1,7 +1,10 @@
 package api
 
 import (
+	"encoding/json"
 	"io"
+	"net/http"
+	"net/url"
 	"reflect"
 
 	"github.com/ohait/forego/ctx"
@@ -16,6 +19,11 @@ type JSON struct {
 
 	Data enc.Map
 	UID  enc.Node
+
+	// parameters bound outside of the body (see `api:"header,..."`, `api:"query,..."` and `api:"path,..."`)
+	Header http.Header
+	Query  url.Values
+	Path   map[string]string
 }
 
 var _ ClientRequest = &JSON{}
@@ -53,7 +61,7 @@ func (this *JSON) ReadFrom(c ctx.C, r io.Reader) error {
 func (this *JSON) Auth(c ctx.C, into reflect.Value, required bool) error {
 	if (this.UID == nil || this.UID == enc.Nil{}) {
 		if required {
-			return ctx.NewErrorf(c, "Auth required")
+			return ctx.NewErrorf(c, "%w", ErrAuthRequired)
 		}
 		return nil
 	}
@@ -87,3 +95,145 @@ func (this *JSON) Unmarshal(c ctx.C, name string, into reflect.Value) error {
 	}
 	return nil
 }
+
+func (this *JSON) MarshalParam(c ctx.C, source, name string, from reflect.Value) error {
+	if from.IsZero() && source != InPath {
+		return nil // no need to send empty headers or query parameters
+	}
+	n, err := this.h.Marshal(c, from.Interface())
+	if err != nil {
+		return ctx.NewErrorf(c, "can't Marshal %s %q: %w", source, name, err)
+	}
+	vals, err := paramValues(c, n)
+	if err != nil {
+		return ctx.NewErrorf(c, "can't Marshal %s %q: %w", source, name, err)
+	}
+	switch source {
+	case InHeader:
+		if this.Header == nil {
+			this.Header = http.Header{}
+		}
+		for _, v := range vals {
+			this.Header.Add(name, v)
+		}
+	case InQuery:
+		if this.Query == nil {
+			this.Query = url.Values{}
+		}
+		for _, v := range vals {
+			this.Query.Add(name, v)
+		}
+	case InPath:
+		if len(vals) != 1 {
+			return ctx.NewErrorf(c, "path parameter %q must be a single value, got %d", name, len(vals))
+		}
+		if this.Path == nil {
+			this.Path = map[string]string{}
+		}
+		this.Path[name] = vals[0]
+	default:
+		return ctx.NewErrorf(c, "unknown parameter source %q", source)
+	}
+	return nil
+}
+
+var _ FieldChecker = &JSON{}
+
+func (this *JSON) Has(source, name string) bool {
+	switch source {
+	case "":
+		_, ok := this.Data[name]
+		return ok
+	case InHeader:
+		return len(this.Header.Values(name)) > 0
+	case InQuery:
+		return len(this.Query[name]) > 0
+	case InPath:
+		_, ok := this.Path[name]
+		return ok
+	}
+	return false
+}
+
+func (this *JSON) UnmarshalParam(c ctx.C, source, name string, into reflect.Value) error {
+	var vals []string
+	switch source {
+	case InHeader:
+		vals = this.Header.Values(name)
+	case InQuery:
+		vals = this.Query[name]
+	case InPath:
+		if v, ok := this.Path[name]; ok {
+			vals = []string{v}
+		}
+	default:
+		return ctx.NewErrorf(c, "unknown parameter source %q", source)
+	}
+	if len(vals) == 0 {
+		return nil
+	}
+	t := into.Type()
+	var n enc.Node
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		list := enc.List{}
+		for _, v := range vals {
+			list = append(list, paramNode(t.Elem(), v))
+		}
+		n = list
+	} else {
+		n = paramNode(t, vals[0])
+	}
+	err := this.h.Unmarshal(c, n, into.Addr().Interface())
+	if err != nil {
+		return ctx.NewErrorf(c, "can't Unmarshal %s %q: %w", source, name, err)
+	}
+	return nil
+}
+
+// parameters are plain strings, convert them to a node which can be unmarshalled into t
+func paramNode(t reflect.Type, s string) enc.Node {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.String || !json.Valid([]byte(s)) {
+		return enc.String(s)
+	}
+	n, err := enc.JSON{}.Decode(ctx.TODO(), []byte(s))
+	if err != nil || n == nil {
+		return enc.String(s)
+	}
+	return n
+}
+
+// converts a node into the list of strings used for headers, query or path
+func paramValues(c ctx.C, n enc.Node) ([]string, error) {
+	switch n := n.(type) {
+	case nil, enc.Nil:
+		return nil, nil
+	case enc.String:
+		return []string{string(n)}, nil
+	case enc.List:
+		var out []string
+		for _, e := range n {
+			switch e.(type) {
+			case enc.List, enc.Map, enc.Pairs:
+				return nil, ctx.NewErrorf(c, "nested %T not supported as parameter", e)
+			}
+			vals, err := paramValues(c, e)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, vals...)
+		}
+		return out, nil
+	case enc.Map, enc.Pairs:
+		return nil, ctx.NewErrorf(c, "%T not supported as parameter", n)
+	default:
+		j := enc.JSON{}.Encode(c, n)
+		var s string
+		if json.Unmarshal(j, &s) == nil {
+			return []string{s}, nil // e.g. enc.Time
+		}
+		return []string{string(j)}, nil
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"reflect"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/ctx/log"
+	"github.com/ohait/forego/enc"
+)
+
+// LogIn returns the `in` fields of obj as they should be logged, e.g. `log.Debug(c, "request", log.F("in", h.LogIn(obj)))`
+// the fields are marshalled only if the line is emitted, and the ones with a `redact` struct tag (also nested) are replaced with "***"
+func (this Handler[T]) LogIn(obj T) log.Loggable {
+	return logged{this.in, reflect.ValueOf(obj)}
+}
+
+// LogOut is like LogIn() but for the `out` fields
+func (this Handler[T]) LogOut(obj T) log.Loggable {
+	return logged{this.out, reflect.ValueOf(obj)}
+}
+
+type logged struct {
+	fields []field
+	v      reflect.Value
+}
+
+func (this logged) LogAs(*log.Tags) any {
+	c := ctx.TODO()
+	h := enc.Handler{Redact: true}
+	v := this.v
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	out := enc.Map{}
+	for _, f := range this.fields {
+		if f.tag.redact {
+			out[f.tag.name] = enc.String("***")
+			continue
+		}
+		n, err := h.Marshal(c, v.Field(f.i).Interface())
+		if err != nil {
+			n = enc.String("can't marshal: " + err.Error())
+		}
+		out[f.tag.name] = n
+	}
+	return out
+}
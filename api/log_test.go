@@ -0,0 +1,32 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/ohait/forego/api"
+	"github.com/ohait/forego/ctx/log"
+	"github.com/ohait/forego/test"
+)
+
+type Login struct {
+	User  string `api:"in" json:"user"`
+	Pass  string `api:"in" json:"pass" redact:"true"`
+	Token string `api:"out" json:"session"`
+}
+
+func TestLog(t *testing.T) {
+	c := test.Context(t)
+	h, err := api.NewHandler(c, &Login{})
+	test.NoError(t, err)
+
+	logs := []log.Line{}
+	c = log.WithLogger(c, func(l log.Line) { logs = append(logs, l) })
+	obj := &Login{User: "bob", Pass: "hunter2", Token: "s3cr3t"}
+	log.Info(c, "login", log.F("in", h.LogIn(obj)), log.F("out", h.LogOut(obj)))
+	test.EqualsGo(t, 1, len(logs))
+	test.EqualsStr(t, `{"pass":"***","user":"bob"}`, logs[0].Fields["in"].String())
+	test.EqualsStr(t, `{"session":"s3cr3t"}`, logs[0].Fields["out"].String())
+
+	log.Info(c, "login", log.F("req", map[string]any{"Token": obj.Token}))
+	test.EqualsStr(t, `{"Token":"***"}`, logs[1].Fields["req"].String()) // from the deny list
+}
@@ -0,0 +1,230 @@
+// Package gen generates typed clients from the `openapi.Service` exposed by `http.Server` on `/openapi.json`
+package gen
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ohait/forego/api/openapi"
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/ctx/log"
+)
+
+// an operation, one per path registered with `http.Server.RegisterAPI`
+type op struct {
+	Name   string // unique, e.g. "GetItem"
+	Path   string // e.g. "/api/items/{id}"
+	Doc    string
+	JWT    bool // the op accepts a bearer token
+	Fields []opField
+}
+
+type opField struct {
+	Name     string // unique within the op, e.g. "ID"
+	Wire     string // json name for in/out, parameter name otherwise
+	Schema   *openapi.Schema
+	In       bool
+	Out      bool
+	Source   string // "header", "query" or "path", for parameters
+	Required bool
+	Doc      string
+}
+
+// find all the ops in the service, sorted by path
+// only the paths with a POST generated by `api.Handler` are considered (those with a Summary)
+func ops(c ctx.C, svc *openapi.Service) ([]op, error) {
+	var paths []string
+	for path := range svc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var out []op
+	used := map[string]bool{}
+	for _, path := range paths {
+		pi := svc.Paths[path].Post
+		if pi == nil || pi.Summary == "" {
+			log.Debugf(c, "skipping %q: not an api", path)
+			continue
+		}
+		name := pi.Summary[strings.LastIndex(pi.Summary, ".")+1:]
+		name = goName(name)
+		if used[name] {
+			name = goName(path)
+		}
+		o := op{
+			Name: uniq(name, used),
+			Path: path,
+			Doc:  pi.Description,
+		}
+		for _, sec := range pi.Security {
+			if _, ok := sec["jwt"]; ok {
+				o.JWT = true
+			}
+		}
+
+		fields := map[string]*opField{}
+		add := func(wire string, s *openapi.Schema) *opField {
+			f := fields[wire]
+			if f == nil {
+				f = &opField{Wire: wire, Schema: s}
+				fields[wire] = f
+			}
+			return f
+		}
+		if pi.RequestBody != nil {
+			in := deref(svc, pi.RequestBody.Content["application/json"].Schema)
+			for _, name := range sortedKeys(in.Properties) {
+				f := add(name, in.Properties[name])
+				f.In = true
+				f.Required = contains(in.Required, name)
+				f.Doc = in.Properties[name].Description
+			}
+		}
+		if res, ok := pi.Responses["200"]; ok {
+			out := deref(svc, res.Content["application/json"].Schema)
+			for _, name := range sortedKeys(out.Properties) {
+				f := add(name, out.Properties[name])
+				f.Out = true
+			}
+		}
+
+		names := map[string]bool{"Client": true} // reserved for the http.Client
+		if o.JWT {
+			names["Authorization"] = true
+		}
+		for _, name := range sortedKeys(fields) {
+			f := fields[name]
+			f.Name = uniq(goName(f.Wire), names)
+			o.Fields = append(o.Fields, *f)
+		}
+		for _, p := range pi.Parameters {
+			o.Fields = append(o.Fields, opField{
+				Name:     uniq(goName(p.Name), names),
+				Wire:     p.Name,
+				Schema:   p.Schema,
+				Source:   p.In,
+				Required: p.Required,
+				Doc:      p.Description,
+			})
+		}
+		out = append(out, o)
+	}
+	if len(out) == 0 {
+		return nil, ctx.NewErrorf(c, "no api found in %d paths", len(paths))
+	}
+	return out, nil
+}
+
+// named types, from "#/components/schemas/..."
+type components struct {
+	svc   *openapi.Service
+	names map[string]string // key => name
+	used  map[string]bool
+	queue []string // keys, in order of discovery
+}
+
+func newComponents(svc *openapi.Service, ops []op) *components {
+	this := &components{
+		svc:   svc,
+		names: map[string]string{},
+		used:  map[string]bool{},
+	}
+	for _, o := range ops {
+		this.used[o.Name] = true
+	}
+	return this
+}
+
+// return the type name for the given reference, and enqueue it if it's the first time
+func (this *components) name(ref string) string {
+	key := strings.TrimPrefix(ref, "#/components/schemas/")
+	if name, ok := this.names[key]; ok {
+		return name
+	}
+	s := this.svc.Components.Schemas[key]
+	name := key
+	if s != nil && s.Format != "" {
+		name = s.Format[strings.LastIndex(s.Format, ".")+1:]
+	} else if i := strings.LastIndex(key, "_"); i >= 0 {
+		name = key[i+1:]
+	}
+	name = goName(name)
+	if this.used[name] {
+		name = goName(key)
+	}
+	name = uniq(name, this.used)
+	this.names[key] = name
+	this.queue = append(this.queue, key)
+	return name
+}
+
+// follow references and single allOf
+func deref(svc *openapi.Service, s *openapi.Schema) *openapi.Schema {
+	for s != nil {
+		switch {
+		case s.Reference != "":
+			s = svc.Components.Schemas[strings.TrimPrefix(s.Reference, "#/components/schemas/")]
+		case len(s.AllOf) == 1:
+			s = s.AllOf[0]
+		default:
+			return s
+		}
+	}
+	return &openapi.Schema{}
+}
+
+var initialisms = map[string]bool{
+	"api": true, "http": true, "id": true, "ip": true, "json": true, "uid": true, "url": true, "uuid": true,
+}
+
+// convert a name into an exported go identifier, e.g. "item_id" => "ItemID"
+func goName(s string) string {
+	var out strings.Builder
+	for _, word := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if initialisms[strings.ToLower(word)] {
+			out.WriteString(strings.ToUpper(word))
+			continue
+		}
+		r := []rune(word)
+		r[0] = unicode.ToUpper(r[0])
+		out.WriteString(string(r))
+	}
+	name := out.String()
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
+
+// return name, or name followed by a number if already used, and mark it as used
+func uniq(name string, used map[string]bool) string {
+	out := name
+	for i := 2; used[out]; i++ {
+		out = name + strconv.Itoa(i)
+	}
+	used[out] = true
+	return out
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package gen_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ohait/forego/api/openapi"
+	"github.com/ohait/forego/api/openapi/gen"
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/enc"
+	"github.com/ohait/forego/http"
+	"github.com/ohait/forego/test"
+)
+
+type Item struct {
+	Name    string            `json:"name"`
+	Tags    []string          `json:"tags"`
+	Created time.Time         `json:"created"`
+	Attrs   map[string]int    `json:"attrs"`
+	Parent  *Item             `json:"parent"`
+	Extra   struct{ N int64 } `json:"extra"`
+}
+
+type GetItem struct {
+	UID string `api:"auth,required"`
+
+	ID      string `api:"path,id"`
+	Version int    `api:"query,v"`
+	RID     string `api:"header,X-Request-Id"`
+
+	Fields []string `api:"in,required" json:"fields" doc:"fields to return"`
+	Item   Item     `api:"out" json:"item"`
+}
+
+func (this *GetItem) Do(c ctx.C) error {
+	return nil
+}
+
+type SetItem struct {
+	Item Item `api:"in,out" json:"item"`
+	Prev Item `api:"out" json:"prev"`
+}
+
+func (this *SetItem) Do(c ctx.C) error {
+	return nil
+}
+
+func service(t *testing.T) *openapi.Service {
+	c := test.Context(t)
+	s := http.NewServer(c)
+	s.MustRegisterAPI(c, "/items/{id}", &GetItem{})
+	s.MustRegisterAPI(c, "/items", &SetItem{})
+	s.HandleRequest("/raw", func(r *http.Request) (any, error) {
+		return nil, nil
+	})
+
+	// same as reading /openapi.json
+	j := enc.MustMarshalJSON(c, s.OpenAPI)
+	var svc openapi.Service
+	test.NoError(t, enc.UnmarshalJSON(c, j, &svc))
+	return &svc
+}
+
+func TestGo(t *testing.T) {
+	c := test.Context(t)
+	src, err := gen.Go(c, service(t), "items")
+	t.Logf("%s", src)
+	test.NoError(t, err)
+	src = []byte(strings.Join(strings.Fields(string(src)), " ")) // ignore alignment
+
+	test.Contains(t, string(src), "package items")
+	test.Contains(t, string(src), "type GetItem struct {")
+	test.Contains(t, string(src), "Authorization string `api:\"header,Authorization\"`")
+	test.Contains(t, string(src), "ID string `api:\"path,id,required\"`")
+	test.Contains(t, string(src), "XRequestID string `api:\"header,X-Request-Id\"`")
+	test.Contains(t, string(src), "Fields []string `api:\"in,required\" json:\"fields\"` // fields to return")
+	test.Contains(t, string(src), "Item Item `api:\"out\" json:\"item\"`")
+	test.Contains(t, string(src), "return this.Client.API(c, this, \"/items/{id}\")")
+	test.Contains(t, string(src), "Item Item `api:\"in,out\" json:\"item\"`")
+	test.Contains(t, string(src), "type Item struct {")
+	test.Contains(t, string(src), "Parent *Item")
+	test.Contains(t, string(src), "Created time.Time")
+	test.Contains(t, string(src), "Attrs map[string]int")
+	test.Assert(t, !strings.Contains(string(src), "Raw"))
+}
+
+func TestTypeScript(t *testing.T) {
+	c := test.Context(t)
+	src, err := gen.TypeScript(c, service(t))
+	t.Logf("%s", src)
+	test.NoError(t, err)
+
+	test.Contains(t, string(src), "export interface GetItemRequest {")
+	test.Contains(t, string(src), "  fields: string[]; // fields to return")
+	test.Contains(t, string(src), `  "X-Request-Id"?: string;`)
+	test.Contains(t, string(src), "export function getItem(req: GetItemRequest, opts: Options = {}): Promise<GetItemResponse> {")
+	test.Contains(t, string(src), `["path", "id", req.id]`)
+	test.Contains(t, string(src), "export interface Item {")
+	test.Contains(t, string(src), " attrs?: Record<string, number>;")
+	test.Contains(t, string(src), "export class ApiError extends Error {")
+}
@@ -0,0 +1,165 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+
+	"github.com/ohait/forego/api/openapi"
+	"github.com/ohait/forego/ctx"
+)
+
+// generate a go file with a struct per api, each calling `http.Client.API()` in `Do()`:
+//
+//	op := client.GetItem{Client: cli, ID: "123"}
+//	err := op.Do(c)
+func Go(c ctx.C, svc *openapi.Service, pkg string) ([]byte, error) {
+	if pkg == "" {
+		pkg = "client"
+	}
+	ops, err := ops(c, svc)
+	if err != nil {
+		return nil, err
+	}
+	g := &goGen{
+		components: newComponents(svc, ops),
+		imports:    map[string]bool{},
+	}
+
+	body := &bytes.Buffer{}
+	for _, o := range ops {
+		g.op(body, o)
+	}
+	for i := 0; i < len(g.queue); i++ { // the queue grows while rendering
+		key := g.queue[i]
+		s := svc.Components.Schemas[key]
+		fmt.Fprintf(body, "\ntype %s %s\n", g.names[key], g.typeOf(s))
+	}
+
+	out := &bytes.Buffer{}
+	fmt.Fprintf(out, "// Code generated by forego apigen from %q; DO NOT EDIT.\n\n", svc.Info.Title)
+	fmt.Fprintf(out, "package %s\n\nimport (\n", pkg)
+	for _, imp := range sortedKeys(g.imports) {
+		fmt.Fprintf(out, "\t%q\n", imp)
+	}
+	fmt.Fprintf(out, ")\n")
+	out.Write(body.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return out.Bytes(), ctx.NewErrorf(c, "can't format generated code: %w", err)
+	}
+	return src, nil
+}
+
+type goGen struct {
+	*components
+	imports map[string]bool
+}
+
+func (this *goGen) op(w *bytes.Buffer, o op) {
+	this.imports["github.com/ohait/forego/ctx"] = true
+	this.imports["github.com/ohait/forego/http"] = true
+
+	fmt.Fprintf(w, "\n// %s calls %s\n", o.Name, o.Path)
+	if o.Doc != "" {
+		fmt.Fprintf(w, "//\n// %s\n", strings.ReplaceAll(o.Doc, "\n", "\n// "))
+	}
+	fmt.Fprintf(w, "type %s struct {\n", o.Name)
+	fmt.Fprintf(w, "\tClient http.Client `json:\"-\"`\n\n")
+	if o.JWT {
+		fmt.Fprintf(w, "\tAuthorization string `api:\"header,Authorization\"` // e.g. \"Bearer <jwt>\"\n")
+	}
+	for _, f := range o.Fields {
+		var tag []string
+		switch {
+		case f.Source != "":
+			tag = append(tag, f.Source, f.Wire)
+		case f.In && f.Out:
+			tag = append(tag, "in", "out")
+		case f.In:
+			tag = append(tag, "in")
+		default:
+			tag = append(tag, "out")
+		}
+		if f.Required {
+			tag = append(tag, "required")
+		}
+		fmt.Fprintf(w, "\t%s %s `api:%q", f.Name, this.typeOf(f.Schema), strings.Join(tag, ","))
+		if f.Source == "" {
+			fmt.Fprintf(w, " json:%q", f.Wire)
+		}
+		fmt.Fprintf(w, "`")
+		if f.Doc != "" {
+			fmt.Fprintf(w, " // %s", strings.ReplaceAll(f.Doc, "\n", " "))
+		}
+		fmt.Fprintf(w, "\n")
+	}
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "func (this *%s) Do(c ctx.C) error {\n", o.Name)
+	fmt.Fprintf(w, "\treturn this.Client.API(c, this, %q)\n", o.Path)
+	fmt.Fprintf(w, "}\n")
+}
+
+func (this *goGen) typeOf(s *openapi.Schema) string {
+	switch {
+	case s == nil:
+		return "any"
+	case s.Reference != "":
+		return this.name(s.Reference)
+	case len(s.AllOf) == 1:
+		return this.typeOf(s.AllOf[0])
+	}
+	switch s.Type {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			this.imports["time"] = true
+			return "time.Time"
+		case "byte":
+			return "[]byte"
+		}
+		return "string"
+	case "boolean":
+		return "bool"
+	case "integer", "number":
+		switch s.Format {
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "float32", "float64":
+			return s.Format
+		}
+		if s.Type == "integer" {
+			return "int64"
+		}
+		return "float64"
+	case "array":
+		return "[]" + this.typeOf(s.Items)
+	case "object":
+		if s.AdditionalProps != nil {
+			return "map[string]" + this.typeOf(s.AdditionalProps)
+		}
+		if len(s.Properties) == 0 {
+			return "any"
+		}
+		w := &strings.Builder{}
+		w.WriteString("struct {\n")
+		used := map[string]bool{}
+		for _, name := range sortedKeys(s.Properties) {
+			fmt.Fprintf(w, "\t%s %s `json:%q`\n", uniq(goName(name), used), this.propType(s.Properties[name]), name)
+		}
+		w.WriteString("}")
+		return w.String()
+	}
+	return "any"
+}
+
+// named structs are pointers when nested, since they might be recursive
+func (this *goGen) propType(s *openapi.Schema) string {
+	t := this.typeOf(s)
+	if s != nil && (s.Reference != "" || len(s.AllOf) == 1) {
+		if d := deref(this.svc, s); d.Type == "object" && d.AdditionalProps == nil && len(d.Properties) > 0 {
+			return "*" + t
+		}
+	}
+	return t
+}
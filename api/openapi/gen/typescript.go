@@ -0,0 +1,214 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/ohait/forego/api/openapi"
+	"github.com/ohait/forego/ctx"
+)
+
+// generate a typescript module with an async function per api, using `fetch()`:
+//
+//	const res = await getItem({ id: "123" }, { baseUrl: "https://example.com" })
+//
+// errors are thrown as `ApiError`, built from the `application/problem+json` response when possible
+func TypeScript(c ctx.C, svc *openapi.Service) ([]byte, error) {
+	ops, err := ops(c, svc)
+	if err != nil {
+		return nil, err
+	}
+	g := &tsGen{newComponents(svc, ops)}
+
+	w := &bytes.Buffer{}
+	fmt.Fprintf(w, "// Code generated by forego apigen from %q; DO NOT EDIT.\n", svc.Info.Title)
+	w.WriteString(tsRuntime)
+	for _, o := range ops {
+		g.op(w, o)
+	}
+	for i := 0; i < len(g.queue); i++ { // the queue grows while rendering
+		key := g.queue[i]
+		s := deref(svc, svc.Components.Schemas[key])
+		if s.Type == "object" && s.AdditionalProps == nil && len(s.Properties) > 0 {
+			fmt.Fprintf(w, "\nexport interface %s %s\n", g.names[key], g.typeOf(s, ""))
+		} else {
+			fmt.Fprintf(w, "\nexport type %s = %s;\n", g.names[key], g.typeOf(s, ""))
+		}
+	}
+	return w.Bytes(), nil
+}
+
+type tsGen struct {
+	*components
+}
+
+func (this *tsGen) op(w *bytes.Buffer, o op) {
+	var req, res, body, params []string
+	if o.JWT {
+		req = append(req, "  Authorization?: string; // e.g. \"Bearer <jwt>\"")
+		params = append(params, `["header", "Authorization", req.Authorization]`)
+	}
+	for _, f := range o.Fields {
+		opt := "?"
+		if f.Required {
+			opt = ""
+		}
+		doc := ""
+		if f.Doc != "" {
+			doc = " // " + strings.ReplaceAll(f.Doc, "\n", " ")
+		}
+		if f.In || f.Source != "" {
+			req = append(req, fmt.Sprintf("  %s%s: %s;%s", tsKey(f.Wire), opt, this.typeOf(f.Schema, "  "), doc))
+		}
+		if f.Out {
+			res = append(res, fmt.Sprintf("  %s?: %s;", tsKey(f.Wire), this.typeOf(f.Schema, "  ")))
+		}
+		switch {
+		case f.Source != "":
+			params = append(params, fmt.Sprintf("[%q, %q, req%s]", f.Source, f.Wire, tsAccess(f.Wire)))
+		case f.In:
+			body = append(body, fmt.Sprintf("%s: req%s", tsKey(f.Wire), tsAccess(f.Wire)))
+		}
+	}
+
+	name := []rune(o.Name)
+	name[0] = unicode.ToLower(name[0])
+	fmt.Fprintf(w, "\nexport interface %sRequest {\n%s}\n", o.Name, lines(req))
+	fmt.Fprintf(w, "\nexport interface %sResponse {\n%s}\n", o.Name, lines(res))
+	if o.Doc != "" {
+		fmt.Fprintf(w, "\n/** %s */", strings.ReplaceAll(o.Doc, "*/", "* /"))
+	}
+	fmt.Fprintf(w, "\nexport function %s(req: %sRequest, opts: Options = {}): Promise<%sResponse> {\n", string(name), o.Name, o.Name)
+	fmt.Fprintf(w, "  return call(%q, {%s}, [%s], opts);\n}\n", o.Path, strings.Join(body, ", "), strings.Join(params, ", "))
+}
+
+func (this *tsGen) typeOf(s *openapi.Schema, indent string) string {
+	switch {
+	case s == nil:
+		return "any"
+	case s.Reference != "":
+		return this.name(s.Reference)
+	case len(s.AllOf) == 1:
+		return this.typeOf(s.AllOf[0], indent)
+	}
+	switch s.Type {
+	case "string":
+		return "string"
+	case "boolean":
+		return "boolean"
+	case "integer", "number":
+		return "number"
+	case "array":
+		t := this.typeOf(s.Items, indent)
+		if strings.ContainsAny(t, " |") {
+			return "Array<" + t + ">"
+		}
+		return t + "[]"
+	case "object":
+		if s.AdditionalProps != nil {
+			return "Record<string, " + this.typeOf(s.AdditionalProps, indent) + ">"
+		}
+		if len(s.Properties) == 0 {
+			return "any"
+		}
+		var props []string
+		for _, name := range sortedKeys(s.Properties) {
+			props = append(props, fmt.Sprintf("%s  %s?: %s;", indent, tsKey(name), this.typeOf(s.Properties[name], indent+"  ")))
+		}
+		return "{\n" + lines(props) + indent + "}"
+	}
+	return "any"
+}
+
+// quote the key if it's not a valid identifier
+func tsKey(s string) string {
+	for i, r := range s {
+		if !unicode.IsLetter(r) && r != '_' && r != '$' && (i == 0 || !unicode.IsDigit(r)) {
+			return fmt.Sprintf("%q", s)
+		}
+	}
+	return s
+}
+
+func tsAccess(s string) string {
+	k := tsKey(s)
+	if strings.HasPrefix(k, `"`) {
+		return "[" + k + "]"
+	}
+	return "." + k
+}
+
+func lines(list []string) string {
+	if len(list) == 0 {
+		return ""
+	}
+	return strings.Join(list, "\n") + "\n"
+}
+
+const tsRuntime = `
+export interface Options {
+  baseUrl?: string;
+  headers?: Record<string, string>;
+  signal?: AbortSignal;
+  fetch?: typeof fetch;
+}
+
+export interface FieldError {
+  path: string;
+  message: string;
+}
+
+// an RFC 7807 problem, as sent by forego http.Server
+export class ApiError extends Error {
+  constructor(
+    public status: number,
+    public code: string,
+    message: string,
+    public fields?: FieldError[],
+    public tracking?: string,
+  ) {
+    super(message);
+  }
+}
+
+async function call<T>(path: string, body: Record<string, any>, params: [string, string, any][], opts: Options): Promise<T> {
+  const headers: Record<string, string> = { "Content-Type": "application/json", ...opts.headers };
+  const query = new URLSearchParams();
+  for (const [source, name, value] of params) {
+    if (value === undefined || value === null) {
+      continue;
+    }
+    switch (source) {
+      case "path":
+        path = path.replace("{" + name + "}", encodeURIComponent(String(value)));
+        break;
+      case "query":
+        for (const v of Array.isArray(value) ? value : [value]) {
+          query.append(name, String(v));
+        }
+        break;
+      case "header":
+        headers[name] = String(value);
+        break;
+    }
+  }
+  const qs = query.toString();
+  const res = await (opts.fetch ?? fetch)((opts.baseUrl ?? "") + path + (qs ? "?" + qs : ""), {
+    method: "POST",
+    headers,
+    body: JSON.stringify(body),
+    signal: opts.signal,
+  });
+  if (res.ok) {
+    const text = await res.text();
+    return (text ? JSON.parse(text) : {}) as T;
+  }
+  if ((res.headers.get("Content-Type") ?? "").startsWith("application/problem+json")) {
+    const p = await res.json();
+    throw new ApiError(p.status, p.code, p.detail ?? p.title, p.fields, p.tracking);
+  }
+  throw new ApiError(res.status, "", res.statusText);
+}
+`
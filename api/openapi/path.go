@@ -1,5 +1,10 @@
 package openapi
 
+import (
+	"maps"
+	"slices"
+)
+
 type Path struct {
 	Post    *PathItem `json:"post,omitempty"`
 	Get     *PathItem `json:"get,omitempty"`
@@ -17,6 +22,30 @@ type PathItem struct {
 	Parameters  []Parameter         `json:"parameters,omitempty"`
 }
 
+// return a deep copy, except for the schemas which are shared
+func (this *PathItem) Clone() *PathItem {
+	out := *this
+	if this.RequestBody != nil {
+		rb := *this.RequestBody
+		rb.Content = maps.Clone(rb.Content)
+		out.RequestBody = &rb
+	}
+	out.Servers = slices.Clone(this.Servers)
+	if this.Responses != nil {
+		out.Responses = map[string]Response{}
+		for k, r := range this.Responses {
+			r.Content = maps.Clone(r.Content)
+			out.Responses[k] = r
+		}
+	}
+	out.Security = nil
+	for _, s := range this.Security {
+		out.Security = append(out.Security, maps.Clone(s))
+	}
+	out.Parameters = slices.Clone(this.Parameters)
+	return &out
+}
+
 // add a jwt security, and optionally an empty security unless required is true
 func (this *PathItem) SetJWT(required bool) {
 	this.Security.Append(Security{
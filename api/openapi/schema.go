@@ -26,6 +26,17 @@ type Schema struct {
 	Enum            []string           `json:"enum,omitempty"`
 	AllOf           []*Schema          `json:"allOf,omitempty"`
 
+	// validation (see Validation)
+	Minimum       *float64 `json:"minimum,omitempty"`
+	Maximum       *float64 `json:"maximum,omitempty"`
+	MinLength     *int     `json:"minLength,omitempty"`
+	MaxLength     *int     `json:"maxLength,omitempty"`
+	Pattern       string   `json:"pattern,omitempty"`
+	MinItems      *int     `json:"minItems,omitempty"`
+	MaxItems      *int     `json:"maxItems,omitempty"`
+	MinProperties *int     `json:"minProperties,omitempty"`
+	MaxProperties *int     `json:"maxProperties,omitempty"`
+
 	// Mutually exclusive (if you have a $ref, it will overwrite anything else)
 	// See https://swagger.io/docs/specification/using-ref/
 	Reference string `json:"$ref,omitempty"`
@@ -38,7 +49,15 @@ func (this *Service) SchemaFromType(c ctx.C, t reflect.Type, tags *reflect.Struc
 		example = strings.TrimSpace(tags.Get("example"))
 	}
 	s, err := this.schemaFromType(c, t, doc, example)
-	return s, err
+	if err != nil || tags == nil {
+		return s, err
+	}
+	v, err := ParseValidation(tags.Get("validate"))
+	if err != nil {
+		return s, ctx.WrapError(c, err)
+	}
+	v.Apply(s)
+	return s, nil
 }
 
 func (this *Service) MustSchemaFromType(c ctx.C, obj any) *Schema {
@@ -88,6 +107,18 @@ func (this *Service) schemaFromType(c ctx.C, t reflect.Type, doc, example string
 			Example: example,
 		}, nil
 
+	case ctx.Error: // the wire form, see ctx.Error.MarshalJSON()
+		return &Schema{
+			Type:   "object",
+			Format: "ctx.Error",
+			Properties: map[string]*Schema{
+				"error":    {Type: "string"},
+				"stack":    {Type: "array", Items: &Schema{Type: "string"}},
+				"tags":     {Type: "object"},
+				"tracking": {Type: "string"},
+			},
+		}, nil
+
 	case json.Marshaler, enc.Marshaler:
 		// Handle corner case where a type can be struct, but marshalled to a primitive json type
 		// In openapi schema, we want to describe the marshalled json type
@@ -212,7 +243,7 @@ func (this *Service) schemaFromType(c ctx.C, t reflect.Type, doc, example string
 			var fields []string
 			for i := 0; i < tt.NumField(); i++ {
 				f := tt.Field(i)
-				s, err := this.schemaFromType(c, f.Type, f.Tag.Get("doc"), strings.TrimSpace(f.Tag.Get("example")))
+				s, err := this.SchemaFromType(c, f.Type, &f.Tag)
 				if err != nil {
 					return structSchema, err
 				}
@@ -187,3 +187,38 @@ func TestSchema(t *testing.T) {
 	test.EqualsGo(t, "bearer", s.Components.SecurityScheme["jwt"].Scheme)
 	test.EqualsGo(t, "JWT", s.Components.SecurityScheme["jwt"].BearerFormat)
 }
+
+type Validated struct {
+	Name string   `json:"name" validate:"maxlen=256,pattern=^[a-z]+$"`
+	Size int      `json:"size" validate:"min=1,max=100"`
+	Kind string   `json:"kind" validate:"enum=a|b|c"`
+	Tags []string `json:"tags" validate:"maxlen=3,minlen=1,pattern=^#"`
+}
+
+func TestSchemaValidation(t *testing.T) {
+	c := test.Context(t)
+
+	s := openapi.NewService("test-validation")
+	_, err := s.SchemaFromType(c, reflect.TypeOf(Validated{}), nil)
+	test.NoError(t, err)
+	sc := s.Components.Schemas["github.com_ohait_forego_api_openapi_test_Validated"]
+	test.NotNil(t, sc)
+	t.Logf("Schema: %s", enc.JSON{Indent: true}.Encode(c, enc.MustMarshal(c, sc)))
+
+	test.EqualsGo(t, 256, *sc.Properties["name"].MaxLength)
+	test.EqualsGo(t, "^[a-z]+$", sc.Properties["name"].Pattern)
+	test.EqualsGo(t, 1.0, *sc.Properties["size"].Minimum)
+	test.EqualsGo(t, 100.0, *sc.Properties["size"].Maximum)
+	test.EqualsGo(t, []string{"a", "b", "c"}, sc.Properties["kind"].Enum)
+	test.EqualsGo(t, 3, *sc.Properties["tags"].MaxItems)
+	test.EqualsGo(t, 1, *sc.Properties["tags"].MinItems)
+	test.EqualsGo(t, "^#", sc.Properties["tags"].Items.Pattern)
+
+	v, err := openapi.ParseValidation("pattern=^[a-z]{1,3}$,maxlen=3")
+	test.NoError(t, err)
+	test.EqualsGo(t, "^[a-z]{1,3}$", v.Pattern.String())
+	test.EqualsGo(t, 3, *v.MaxLen)
+
+	_, err = openapi.ParseValidation("foo=bar")
+	test.Error(t, err)
+}
@@ -0,0 +1,121 @@
+package openapi
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Validation holds the constraints of a `validate` tag, e.g.:
+//
+//	Name string `validate:"minlen=1,maxlen=256,pattern=^[a-z]+$"`
+//	Size int    `validate:"min=1,max=100"`
+//	Kind string `validate:"enum=a|b|c"`
+//
+// For slices and maps, minlen and maxlen apply to the number of items, while the other constraints apply to each item.
+type Validation struct {
+	Min     *float64
+	Max     *float64
+	MinLen  *int
+	MaxLen  *int
+	Pattern *regexp.Regexp
+	Enum    []string
+}
+
+var validationKeys = []string{"min=", "max=", "minlen=", "maxlen=", "pattern=", "enum="}
+
+// parse a `validate` tag, returns nil if the tag is empty
+func ParseValidation(tag string) (*Validation, error) {
+	if tag == "" {
+		return nil, nil
+	}
+	// patterns can contain commas, so we join back any part which doesn't start with a known key
+	var parts []string
+	for _, p := range strings.Split(tag, ",") {
+		known := false
+		for _, k := range validationKeys {
+			if strings.HasPrefix(p, k) {
+				known = true
+			}
+		}
+		if !known && len(parts) > 0 {
+			parts[len(parts)-1] += "," + p
+			continue
+		}
+		parts = append(parts, p)
+	}
+
+	out := &Validation{}
+	for _, p := range parts {
+		k, v, _ := strings.Cut(p, "=")
+		switch k {
+		case "min", "max":
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s in validate tag %q: %w", k, tag, err)
+			}
+			if k == "min" {
+				out.Min = &f
+			} else {
+				out.Max = &f
+			}
+		case "minlen", "maxlen":
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s in validate tag %q: %w", k, tag, err)
+			}
+			if k == "minlen" {
+				out.MinLen = &i
+			} else {
+				out.MaxLen = &i
+			}
+		case "pattern":
+			re, err := regexp.Compile(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern in validate tag %q: %w", tag, err)
+			}
+			out.Pattern = re
+		case "enum":
+			out.Enum = strings.Split(v, "|")
+		default:
+			return nil, fmt.Errorf("invalid validate tag %q: unknown %q", tag, p)
+		}
+	}
+	return out, nil
+}
+
+// add the constraints to the given schema
+func (this *Validation) Apply(s *Schema) {
+	if this == nil || s == nil {
+		return
+	}
+	if s.Type == "array" || s.AdditionalProps != nil {
+		if s.Type == "array" {
+			s.MinItems = this.MinLen
+			s.MaxItems = this.MaxLen
+		} else {
+			s.MinProperties = this.MinLen
+			s.MaxProperties = this.MaxLen
+		}
+		items := *this
+		items.MinLen = nil
+		items.MaxLen = nil
+		if s.Items != nil {
+			items.Apply(s.Items)
+		} else {
+			items.Apply(s.AdditionalProps)
+		}
+		return
+	}
+	s.Minimum = this.Min
+	s.Maximum = this.Max
+	s.MinLength = this.MinLen
+	s.MaxLength = this.MaxLen
+	if this.Pattern != nil {
+		s.Pattern = this.Pattern.String()
+	}
+	if this.Enum != nil {
+		s.Enum = this.Enum
+	}
+}
@@ -5,7 +5,16 @@ import (
 	"reflect"
 	"strings"
 
+	"github.com/ohait/forego/api/openapi"
 	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/enc"
+)
+
+// where a field is bound from, beside the body
+const (
+	InHeader = "header"
+	InQuery  = "query"
+	InPath   = "path"
 )
 
 type tag struct {
@@ -14,6 +23,14 @@ type tag struct {
 	in       bool
 	out      bool
 	required bool
+
+	// if not empty, the field is bound to a parameter (header, query or path) instead of the body
+	source string
+	param  string
+
+	validate *openapi.Validation // from the `validate` tag
+
+	redact bool // `redact:"true"`, not logged (see Handler.LogIn())
 }
 
 func tagName(_ ctx.C, f reflect.StructField) string {
@@ -39,7 +56,8 @@ func parseTags(c ctx.C, f reflect.StructField) (tag tag, err error) {
 	if tag.name == "" {
 		tag.name = f.Name // fallback to field name
 	}
-	for _, p := range parts {
+	for i := 0; i < len(parts); i++ {
+		p := parts[i]
 		//log.Debugf(c, "%s %s", f.Name, p)
 		switch p {
 		case "in":
@@ -53,10 +71,34 @@ func parseTags(c ctx.C, f reflect.StructField) (tag tag, err error) {
 			tag.required = true
 		case "auth":
 			tag.auth = true
+		case InHeader, InQuery, InPath:
+			if tag.source != "" {
+				return tag, fmt.Errorf("multiple sources: %q and %q", tag.source, p)
+			}
+			tag.source = p
+			// the parameter name follows the source, e.g. `api:"header,X-Forwarded-For"`
+			if i+1 < len(parts) && parts[i+1] != "required" {
+				i++
+				tag.param = parts[i]
+			}
+			if tag.param == "" {
+				tag.param = tag.name
+			}
 		case "":
 		default:
 			return tag, fmt.Errorf("invalid tag: %q", p)
 		}
 	}
+	tag.redact, err = enc.ParseRedact(f)
+	if err != nil {
+		return tag, err
+	}
+	tag.validate, err = openapi.ParseValidation(f.Tag.Get("validate"))
+	if err != nil {
+		return tag, err
+	}
+	if tag.source != "" && (tag.in || tag.out || tag.auth) {
+		return tag, fmt.Errorf("%s parameter %q can't be combined with in, out, both or auth", tag.source, tag.param)
+	}
 	return tag, nil
 }
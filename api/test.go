@@ -25,7 +25,7 @@ func Test[T Op](c ctx.C, op T) T {
 		test.Fail(t, "%+v", err)
 	}
 
-	err = req.Do(c)
+	err = Do(c, req)
 	test.NoError(t, err)
 
 	j = &JSON{}
@@ -57,6 +57,6 @@ func TestStream[T StreamingOp](t *testing.T, op T, cb func(c ctx.C, obj any) err
 		test.Fail(t, "%+v", err)
 	}
 
-	err = req.Stream(test.Context(t), cb)
+	err = Stream(test.Context(t), req, cb)
 	test.NoError(t, err)
 }
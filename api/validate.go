@@ -0,0 +1,214 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ohait/forego/api/openapi"
+	"github.com/ohait/forego/ctx"
+)
+
+// ValidationError is returned by Server.Recv() when some of the fields are missing or don't
+// pass the constraints of their `validate` tag, it lists all the failing fields
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+type FieldError struct {
+	Path    string `json:"path"` // e.g. "items[2].name"
+	Message string `json:"message"`
+}
+
+func (this ValidationError) Error() string {
+	var parts []string
+	for _, f := range this.Fields {
+		parts = append(parts, f.Path+": "+f.Message)
+	}
+	return "invalid request: " + strings.Join(parts, ", ")
+}
+
+// validate nested structs, built once per handler
+type validator struct {
+	structs map[reflect.Type][]structRule
+}
+
+type structRule struct {
+	i     int
+	name  string
+	rules *openapi.Validation
+}
+
+// inspect the given type and check the rules can be applied to it, also inspect nested structs
+// returns true if the type needs to be validated
+func (this *validator) compile(c ctx.C, t reflect.Type, rules *openapi.Validation) (bool, error) {
+	if rules != nil {
+		err := checkRules(t, rules)
+		if err != nil {
+			return false, ctx.NewErrorf(c, "%w", err)
+		}
+	}
+	for {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+			continue
+		}
+		break
+	}
+	if t.Kind() != reflect.Struct {
+		return rules != nil, nil
+	}
+	if this.structs == nil {
+		this.structs = map[reflect.Type][]structRule{}
+	}
+	if list, seen := this.structs[t]; seen {
+		return rules != nil || len(list) > 0, nil
+	}
+	this.structs[t] = nil // prevent infinite recursion
+	var list []structRule
+	for i := 0; i < t.NumField(); i++ {
+		ft := t.Field(i)
+		if !ft.IsExported() {
+			continue
+		}
+		r, err := openapi.ParseValidation(ft.Tag.Get("validate"))
+		if err != nil {
+			return false, ctx.NewErrorf(c, "%v.%s: %w", t, ft.Name, err)
+		}
+		nested, err := this.compile(c, ft.Type, r)
+		if err != nil {
+			return false, ctx.NewErrorf(c, "%v.%s: %w", t, ft.Name, err)
+		}
+		if nested {
+			list = append(list, structRule{i, tagName(c, ft), r})
+		}
+	}
+	this.structs[t] = list
+	return rules != nil || len(list) > 0, nil
+}
+
+// check the rules are compatible with the type
+func checkRules(t reflect.Type, r *openapi.Validation) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		items := *r
+		items.MinLen = nil
+		items.MaxLen = nil
+		if items.Min == nil && items.Max == nil && items.Pattern == nil && items.Enum == nil {
+			return nil
+		}
+		return checkRules(t.Elem(), &items)
+	case reflect.String:
+		if r.Min != nil || r.Max != nil {
+			return fmt.Errorf("min and max are not supported for %v, use minlen and maxlen", t)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if r.MinLen != nil || r.MaxLen != nil || r.Pattern != nil {
+			return fmt.Errorf("minlen, maxlen and pattern are not supported for %v, use min and max", t)
+		}
+	default:
+		return fmt.Errorf("validate is not supported for %v", t)
+	}
+	return nil
+}
+
+// validate v and any nested struct, v must have been sent: only nil pointers are considered missing
+func (this validator) validate(path string, v reflect.Value, r *openapi.Validation, errs *[]FieldError) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	fail := func(f string, args ...any) {
+		*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf(f, args...)})
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, f := range this.structs[v.Type()] {
+			this.validate(path+"."+f.name, v.Field(f.i), f.rules, errs)
+		}
+		return
+
+	case reflect.Slice, reflect.Array, reflect.Map:
+		var items *openapi.Validation
+		if r != nil {
+			if r.MinLen != nil && v.Len() < *r.MinLen {
+				fail("must have at least %d items", *r.MinLen)
+			}
+			if r.MaxLen != nil && v.Len() > *r.MaxLen {
+				fail("must have at most %d items", *r.MaxLen)
+			}
+			x := *r
+			x.MinLen = nil
+			x.MaxLen = nil
+			items = &x
+		}
+		if v.Kind() == reflect.Map {
+			keys := v.MapKeys()
+			sort.Slice(keys, func(i, j int) bool {
+				return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+			})
+			for _, k := range keys {
+				this.validate(fmt.Sprintf("%s.%v", path, k), v.MapIndex(k), items, errs)
+			}
+		} else {
+			for i := 0; i < v.Len(); i++ {
+				this.validate(fmt.Sprintf("%s[%d]", path, i), v.Index(i), items, errs)
+			}
+		}
+		return
+	}
+
+	if r == nil {
+		return
+	}
+	switch v.Kind() {
+	case reflect.String:
+		s := v.String()
+		n := utf8.RuneCountInString(s)
+		if r.MinLen != nil && n < *r.MinLen {
+			fail("must be at least %d characters", *r.MinLen)
+		}
+		if r.MaxLen != nil && n > *r.MaxLen {
+			fail("must be at most %d characters", *r.MaxLen)
+		}
+		if r.Pattern != nil && !r.Pattern.MatchString(s) {
+			fail("must match %q", r.Pattern.String())
+		}
+		if r.Enum != nil && !slices.Contains(r.Enum, s) {
+			fail("must be one of %s", strings.Join(r.Enum, "|"))
+		}
+	default:
+		var f float64
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f = float64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			f = float64(v.Uint())
+		case reflect.Float32, reflect.Float64:
+			f = v.Float()
+		default:
+			return
+		}
+		if r.Min != nil && f < *r.Min {
+			fail("must be at least %v", *r.Min)
+		}
+		if r.Max != nil && f > *r.Max {
+			fail("must be at most %v", *r.Max)
+		}
+		if r.Enum != nil && !slices.Contains(r.Enum, strconv.FormatFloat(f, 'f', -1, 64)) {
+			fail("must be one of %s", strings.Join(r.Enum, "|"))
+		}
+	}
+}
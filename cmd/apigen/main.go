@@ -0,0 +1,87 @@
+// apigen reads the `/openapi.json` of a forego `http.Server` and generates a typed client
+//
+//	go run github.com/ohait/forego/cmd/apigen -pkg items -o client.go http://localhost:8080/openapi.json
+//	go run github.com/ohait/forego/cmd/apigen -lang ts -o client.ts ./openapi.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ohait/forego/api/openapi"
+	"github.com/ohait/forego/api/openapi/gen"
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/ctx/log"
+	"github.com/ohait/forego/enc"
+	"github.com/ohait/forego/http"
+)
+
+func main() {
+	lang := flag.String("lang", "go", "language to generate: go or ts")
+	pkg := flag.String("pkg", "client", "go package name")
+	out := flag.String("o", "", "output file, stdout if empty")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] <url or file of openapi.json>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c, cf := ctx.Background()
+	defer cf(nil)
+	// stdout is for the generated code, only warnings and errors are logged, to stderr
+	c = log.WithLogger(c, func(l log.Line) {
+		switch l.Level {
+		case "warn", "error":
+			fmt.Fprintln(os.Stderr, l.JSON())
+		}
+	})
+	err := run(c, flag.Arg(0), *lang, *pkg, *out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apigen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(c ctx.C, from, lang, pkg, out string) error {
+	var j []byte
+	var err error
+	if strings.HasPrefix(from, "http://") || strings.HasPrefix(from, "https://") {
+		j, err = http.DefaultClient.Get(c, from)
+	} else {
+		j, err = os.ReadFile(from)
+	}
+	if err != nil {
+		return err
+	}
+
+	var svc openapi.Service
+	err = enc.UnmarshalJSON(c, j, &svc)
+	if err != nil {
+		return ctx.NewErrorf(c, "can't parse %q: %w", from, err)
+	}
+
+	var src []byte
+	switch lang {
+	case "go":
+		src, err = gen.Go(c, &svc, pkg)
+	case "ts":
+		src, err = gen.TypeScript(c, &svc)
+	default:
+		return ctx.NewErrorf(c, "unknown language %q", lang)
+	}
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0o644)
+}
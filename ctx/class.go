@@ -0,0 +1,43 @@
+package ctx
+
+import (
+	"errors"
+)
+
+// Classes of errors, which the transports map to a status (e.g. http returns 404 for ErrNotFound), so
+// business code doesn't need to depend on them. Wrap them, or use Classify to keep the message unchanged:
+//
+//	return ctx.NewErrorf(c, "no user %q: %w", id, ctx.ErrNotFound)
+//	return ctx.Classify(ctx.WrapError(c, err), ctx.ErrUnavailable, ctx.ErrRetryable)
+//
+// Errors received from a remote service are classified by their status, so errors.Is() works on both sides
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrInvalid         = errors.New("invalid")
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrForbidden       = errors.New("forbidden")
+	ErrUnavailable     = errors.New("unavailable")
+	ErrRetryable       = errors.New("retryable") // the same request may succeed later
+)
+
+// return err classified as each of the given classes (e.g. ErrNotFound), with the same message
+func Classify(err error, classes ...error) error {
+	if err == nil || len(classes) == 0 {
+		return err
+	}
+	return &classified{err, classes}
+}
+
+type classified struct {
+	err     error
+	classes []error
+}
+
+func (this *classified) Error() string {
+	return this.err.Error()
+}
+
+func (this *classified) Unwrap() []error {
+	return append([]error{this.err}, this.classes...)
+}
@@ -0,0 +1,27 @@
+package ctx_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/test"
+)
+
+func TestClassify(t *testing.T) {
+	c := test.Context(t)
+	err := ctx.Classify(ctx.WrapError(c, io.EOF), ctx.ErrUnavailable, ctx.ErrRetryable)
+	test.EqualsStr(t, "EOF", err.Error())
+	test.Assert(t, errors.Is(err, io.EOF))
+	test.Assert(t, errors.Is(err, ctx.ErrUnavailable))
+	test.Assert(t, errors.Is(err, ctx.ErrRetryable))
+	test.Assert(t, !errors.Is(err, ctx.ErrNotFound))
+	var cerr ctx.Error
+	test.Assert(t, errors.As(err, &cerr))
+
+	err = ctx.NewErrorf(c, "no user %q: %w", "x", ctx.ErrNotFound)
+	test.Assert(t, errors.Is(err, ctx.ErrNotFound))
+
+	test.Nil(t, ctx.Classify(nil, ctx.ErrNotFound))
+}
@@ -1,9 +1,9 @@
 package ctx
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
-	"runtime"
 )
 
 // NewErrorf formats an error and ensures it carries a stack trace plus the
@@ -26,17 +26,20 @@ func WrapError(c C, err error) error {
 // the stack leading to its creation, and the context active at that time so it
 // can later be inspected or logged with tags intact.
 type Error struct {
-	Err   error    `json:"err"`
-	Stack []string `json:"stack"`
-	C     C        `json:"ctx"`
+	Err   error `json:"err"`
+	Stack Stack `json:"stack"`
+	C     C     `json:"ctx"`
 }
 
 func (err Error) String() string {
-	return err.Err.Error()
+	return err.Error()
 }
 
 // Error implements the error interface by forwarding to the wrapped error.
 func (err Error) Error() string {
+	if err.Err == nil {
+		return ""
+	}
 	return err.Err.Error()
 }
 
@@ -57,30 +60,103 @@ func (this Error) Is(err error) bool {
 }
 
 func maybeWrap(c C, err error) error {
-	if errors.Is(err, Error{}) {
+	// errors received from another process are wrapped again, to get the local stack and context
+	var e Error
+	if errors.As(err, &e) && !e.remote() {
 		return err // already wrapped
 	}
-	if errors.Is(err, &Error{}) {
+	var pe *Error
+	if errors.As(err, &pe) && pe != nil && !pe.remote() {
 		return err // already wrapped
 	}
-	return Error{
-		Err:   err,
-		Stack: stack(2, 100),
-		C:     c,
+	out := Error{
+		Err: err,
+		C:   c,
+	}
+	if !skipStack(err) {
+		out.Stack = stack(2)
 	}
+	return out
+}
+
+// RemoteError is the cause of an Error received from another process (see Error.UnmarshalJSON)
+type RemoteError struct {
+	Message  string
+	Stack    []string        // as returned by Stack.Frames() on the remote side
+	Tags     map[string]JSON // the tags of the remote context
+	Tracking string
+}
+
+func (this RemoteError) Error() string {
+	return this.Message
+}
+
+func (this Error) remote() bool {
+	_, ok := this.Err.(RemoteError)
+	return ok
+}
+
+// the wire form of Error
+type errorJSON struct {
+	Error    string          `json:"error"`
+	Stack    []string        `json:"stack,omitempty"`
+	Tags     map[string]JSON `json:"tags,omitempty"`
+	Tracking string          `json:"tracking,omitempty"`
 }
 
-func stack(above, max int) []string {
-	stack := make([]string, 0, 20)
-	for len(stack) < max {
-		_, file, line, ok := runtime.Caller(above + 1)
-		if !ok {
-			return stack
+// MarshalJSON sends the message, the stack, the tags and the tracking id of the context
+// Note: `enc` imports `ctx`, so enc.Marshaler can't be implemented here, but `enc` handles Error natively
+func (this Error) MarshalJSON() ([]byte, error) {
+	out := errorJSON{
+		Stack: this.Stack.Frames(),
+	}
+	if this.Err != nil {
+		out.Error = this.Err.Error()
+	}
+	if re, ok := this.Err.(RemoteError); ok && len(out.Stack) == 0 {
+		out.Stack = re.Stack // forwarding
+		out.Tracking = re.Tracking
+	}
+	if this.C != nil {
+		out.Tracking = GetTracking(this.C)
+		_ = RangeTag(this.C, func(k string, j JSON) error {
+			if out.Tags == nil {
+				out.Tags = map[string]JSON{}
+			}
+			out.Tags[k] = j
+			return nil
+		})
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON rebuilds an error sent by MarshalJSON, the cause is a RemoteError
+// and C has the remote tags and tracking id (but it's not cancellable and has no deadline)
+func (this *Error) UnmarshalJSON(j []byte) error {
+	var in errorJSON
+	err := json.Unmarshal(j, &in)
+	if err != nil {
+		return err
+	}
+	c := TODO()
+	for k, v := range in.Tags {
+		if k != "tracking-id" {
+			c = WithTag(c, k, v)
 		}
-		stack = append(stack, fmt.Sprintf("%s:%d", file, line))
-		above++
 	}
-	return stack
+	if in.Tracking != "" {
+		c = WithTracking(c, in.Tracking)
+	}
+	*this = Error{
+		Err: RemoteError{
+			Message:  in.Error,
+			Stack:    in.Stack,
+			Tags:     in.Tags,
+			Tracking: in.Tracking,
+		},
+		C: c,
+	}
+	return nil
 }
 
 // JSON behaves like json.RawMessage while remaining printable in log tags.
@@ -1,12 +1,15 @@
 package ctx_test
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"testing"
 
 	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/enc"
 	"github.com/ohait/forego/test"
 )
 
@@ -21,7 +24,7 @@ func TestError(t *testing.T) {
 	test.Assert(t, errors.As(err, &x))
 	t.Logf("err: %T %v", err, err)
 
-	stack := x.Stack[0]
+	stack := x.Stack.Frames()[0]
 	t.Logf("stack: %+v", stack)
 
 	err = ctx.WrapError(c, err)
@@ -40,5 +43,43 @@ func TestError(t *testing.T) {
 	test.Error(t, cerr)
 	t.Logf("err: %s", err.Error())
 
-	test.EqualsStr(t, stack, cerr.Stack[0])
+	test.EqualsStr(t, stack, cerr.Stack.Frames()[0])
+}
+
+func TestErrorJSON(t *testing.T) {
+	c := ctx.WithTracking(ctx.TODO(), "abc")
+	c = ctx.WithTag(c, "user", "bob")
+	err := ctx.NewErrorf(c, "wrap: %w", io.EOF)
+
+	j, err2 := json.Marshal(err)
+	test.NoError(t, err2)
+	t.Logf("json: %s", j)
+	test.ContainsJSON(t, j, `"error":"wrap: EOF"`)
+	test.ContainsJSON(t, j, `"tracking":"abc"`)
+	test.ContainsJSON(t, j, `"user":"bob"`)
+	test.ContainsJSON(t, j, "ctx/error_test.go")
+
+	var remote ctx.Error
+	test.NoError(t, json.Unmarshal(j, &remote))
+	test.EqualsStr(t, "wrap: EOF", remote.Error())
+	test.EqualsStr(t, "abc", ctx.GetTracking(remote.C))
+	var re ctx.RemoteError
+	test.Assert(t, errors.As(remote, &re))
+	test.Assert(t, strings.Contains(re.Stack[0], "ctx/error_test.go"))
+	test.EqualsStr(t, `"bob"`, re.Tags["user"].String())
+
+	// the same via enc
+	n, err2 := enc.Marshal(c, err)
+	test.NoError(t, err2)
+	var remote2 ctx.Error
+	test.NoError(t, enc.Unmarshal(c, n, &remote2))
+	test.EqualsStr(t, "wrap: EOF", remote2.Error())
+
+	// wrapping a remote error adds the local stack and context
+	err = ctx.NewErrorf(ctx.WithTag(ctx.TODO(), "local", 1), "remote: %w", remote)
+	var local ctx.Error
+	test.Assert(t, errors.As(err, &local))
+	test.Assert(t, len(local.Stack) > 0)
+	test.Assert(t, errors.As(err, &re))
+	test.EqualsStr(t, "abc", re.Tracking)
 }
@@ -0,0 +1,48 @@
+package ctx
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Key is a typed key for values stored in the context, to be declared once as a pointer:
+//
+//	var Tenant = &ctx.Key[string]{Name: "tenant", Tag: true}
+//
+//	c = Tenant.With(c, "acme")
+//	tenant, ok := Tenant.Get(c)
+type Key[T any] struct {
+	Name string // used by Tag and in the panic of MustGet
+	Tag  bool   // if true, With also adds the value as a tag (see WithTag), so it's logged
+}
+
+// return a child context holding v, which is also tagged if this.Tag
+func (this *Key[T]) With(c C, v T) C {
+	if this.Tag {
+		c = WithTag(c, this.Name, v)
+	}
+	return WithValue(c, this, v)
+}
+
+// return the value set by the closest With, and false if none
+func (this *Key[T]) Get(c C) (T, bool) {
+	if c == nil {
+		var zero T
+		return zero, false
+	}
+	v, ok := c.Value(this).(T)
+	return v, ok
+}
+
+// like Get, but panics if the value is not set
+func (this *Key[T]) MustGet(c C) T {
+	v, ok := this.Get(c)
+	if !ok {
+		panic(fmt.Sprintf("%v not set", this))
+	}
+	return v
+}
+
+func (this *Key[T]) String() string {
+	return fmt.Sprintf("ctx.Key[%v](%q)", reflect.TypeFor[T](), this.Name)
+}
@@ -0,0 +1,52 @@
+package ctx_test
+
+import (
+	"testing"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/test"
+)
+
+var tenantKey = &ctx.Key[string]{Name: "tenant", Tag: true}
+
+type user struct {
+	ID string
+}
+
+func TestKey(t *testing.T) {
+	c := test.Context(t)
+	userKey := &ctx.Key[*user]{Name: "user"}
+
+	_, ok := tenantKey.Get(c)
+	test.Assert(t, !ok)
+
+	c = tenantKey.With(c, "acme")
+	c = userKey.With(c, &user{ID: "u1"})
+	test.EqualsStr(t, "acme", tenantKey.MustGet(c))
+	test.EqualsStr(t, "u1", userKey.MustGet(c).ID)
+
+	// same name, different key
+	other := &ctx.Key[string]{Name: "tenant"}
+	_, ok = other.Get(c)
+	test.Assert(t, !ok)
+
+	// only the keys with Tag are tagged
+	tags := map[string]string{}
+	_ = ctx.RangeTag(c, func(k string, j ctx.JSON) error {
+		tags[k] = string(j)
+		return nil
+	})
+	test.EqualsStr(t, `"acme"`, tags["tenant"])
+	_, ok = tags["user"]
+	test.Assert(t, !ok)
+
+	// the closest wins
+	c2 := tenantKey.With(c, "other")
+	test.EqualsStr(t, "other", tenantKey.MustGet(c2))
+	test.EqualsStr(t, "acme", tenantKey.MustGet(c))
+
+	defer func() {
+		test.Contains(t, recover(), `ctx.Key[string]("missing") not set`)
+	}()
+	(&ctx.Key[string]{Name: "missing"}).MustGet(c)
+}
@@ -0,0 +1,188 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Console renders the lines as aligned text, colored if Color is set, for humans during local development:
+//
+//	15:04:05.000 INF http/server.go:83       listening to :8080  service=viewer
+//
+// use it with `log.WithLogger(c, log.NewConsole().Log)`, or set FOREGO_LOG=console to make it the default
+type Console struct {
+	W     io.Writer // default os.Stdout
+	Color bool
+	Tags  []string // tags shown inline, all of them if empty
+	Time  string   // layout, default "15:04:05.000"
+}
+
+// a Console on stdout, colored if stdout is a terminal and NO_COLOR is not set
+func NewConsole() Console {
+	st, err := os.Stdout.Stat()
+	return Console{
+		W:     os.Stdout,
+		Color: err == nil && st.Mode()&os.ModeCharDevice != 0 && os.Getenv("NO_COLOR") == "",
+	}
+}
+
+var consoleLock sync.Mutex
+
+func (this Console) Log(l Line) {
+	w := this.W
+	if w == nil {
+		w = os.Stdout
+	}
+	s := this.Format(l) + "\n"
+	consoleLock.Lock()
+	defer consoleLock.Unlock()
+	_, _ = io.WriteString(w, s)
+}
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// render a line, without the trailing newline, it can be used as `log.Stream.Format`
+func (this Console) Format(l Line) string {
+	paint := func(color, s string) string {
+		if !this.Color || color == "" {
+			return s
+		}
+		return color + s + ansiReset
+	}
+	layout := this.Time
+	if layout == "" {
+		layout = "15:04:05.000"
+	}
+
+	var level, color string
+	switch l.Level {
+	case "error":
+		level, color = "ERR", ansiRed
+	case "warn":
+		level, color = "WRN", ansiYellow
+	case "info":
+		level, color = "INF", ansiGreen
+	case "debug":
+		level, color = "DBG", ansiDim
+	default:
+		level = fmt.Sprintf("%-3.3s", strings.ToUpper(l.Level))
+	}
+
+	b := &strings.Builder{}
+	b.WriteString(paint(ansiDim, l.Time.Format(layout)))
+	b.WriteString(" ")
+	b.WriteString(paint(color, level))
+	b.WriteString(" ")
+	b.WriteString(paint(ansiDim, fmt.Sprintf("%-24s", shortSrc(l.Src))))
+	b.WriteString(" ")
+	b.WriteString(l.Message)
+
+	// fields are specific to this line, so they are all shown, before the tags
+	fields := make([]string, 0, len(l.Fields))
+	for k := range l.Fields {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	for _, k := range fields {
+		b.WriteString(" ")
+		b.WriteString(k + "=")
+		b.WriteString(tagValue(l.Fields[k]))
+	}
+
+	keys := this.Tags
+	if len(keys) == 0 {
+		for k := range l.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+	}
+	first := true
+	for _, k := range keys {
+		v, ok := l.Tags[k]
+		if !ok || k == "error" {
+			continue
+		}
+		if first {
+			b.WriteString(" ")
+			first = false
+		}
+		b.WriteString(" ")
+		b.WriteString(paint(ansiCyan, k+"="))
+		b.WriteString(tagValue(v))
+	}
+
+	for _, st := range stacks(l.Tags["error"]) {
+		if len(st.Stack) > 0 { // otherwise the message is enough
+			b.WriteString("\n    ")
+			b.WriteString(paint(color, st.Error))
+			for _, frame := range st.Stack {
+				b.WriteString("\n        ")
+				b.WriteString(paint(ansiDim, frame))
+			}
+		}
+		if r := st.Remote; r != nil && len(r.Stack) > 0 {
+			b.WriteString("\n    ")
+			b.WriteString(paint(color, "remote: "+r.Error))
+			for _, frame := range r.Stack {
+				b.WriteString("\n        ")
+				b.WriteString(paint(ansiDim, frame))
+			}
+		}
+	}
+	return b.String()
+}
+
+// the last 2 elements of the path, e.g. "http/server.go:83"
+func shortSrc(src string) string {
+	i := strings.LastIndex(src, "/")
+	if i < 0 {
+		return src
+	}
+	if j := strings.LastIndex(src[:i], "/"); j >= 0 {
+		return src[j+1:]
+	}
+	return src
+}
+
+// strings without quotes, unless they have spaces
+func tagValue(j []byte) string {
+	var s string
+	if json.Unmarshal(j, &s) == nil && s != "" && !strings.ContainsAny(s, " \t\n\"=") {
+		return s
+	}
+	return string(j)
+}
+
+type errorTag struct {
+	Error  string    `json:"error"`
+	Stack  []string  `json:"stack"`
+	Remote *errorTag `json:"remote"` // see ctx.RemoteError
+}
+
+// decode the "error" tag, as set by formatf(), which can be a single error or a list
+func stacks(j []byte) []errorTag {
+	if len(j) == 0 {
+		return nil
+	}
+	var list []errorTag
+	if json.Unmarshal(j, &list) == nil {
+		return list
+	}
+	var one errorTag
+	if json.Unmarshal(j, &one) == nil {
+		return []errorTag{one}
+	}
+	return nil
+}
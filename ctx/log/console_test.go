@@ -0,0 +1,44 @@
+package log_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/ctx/log"
+	"github.com/ohait/forego/test"
+)
+
+func TestConsole(t *testing.T) {
+	buf := &bytes.Buffer{}
+	c := log.WithLogger(ctx.TODO(), log.Console{W: buf, Tags: []string{"user", "path"}}.Log)
+	c = ctx.WithTag(c, "user", "bob")
+	c = ctx.WithTag(c, "path", "/api/x y")
+	c = ctx.WithTag(c, "hidden", 1)
+
+	log.Infof(c, "hello %d", 42)
+	out := buf.String()
+	t.Logf("\n%s", out)
+	test.Contains(t, out, " INF log/console_test.go:")
+	test.Contains(t, out, "hello 42  user=bob path=\"/api/x y\"\n")
+	test.NotContains(t, out, "hidden")
+
+	buf.Reset()
+	log.Info(c, "paid", log.F("n", 3), log.F("by", "alice"))
+	test.Contains(t, buf.String(), "paid by=alice n=3  user=bob")
+
+	buf.Reset()
+	log.Errorf(c, "failed: %v", ctx.WrapError(c, io.EOF))
+	out = buf.String()
+	t.Logf("\n%s", out)
+	test.Contains(t, out, " ERR ")
+	test.Contains(t, out, "\n    EOF\n        ")
+	test.Assert(t, strings.Count(out, "\n") > 2)
+
+	buf.Reset()
+	c = log.WithLogger(ctx.TODO(), log.Console{W: buf, Color: true}.Log)
+	log.Warnf(c, "colored")
+	test.Contains(t, buf.String(), "\x1b[33mWRN\x1b[0m")
+}
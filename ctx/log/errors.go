@@ -0,0 +1,153 @@
+package log
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ohait/forego/ctx"
+)
+
+// number of stack frames used by Fingerprint()
+const fingerprintFrames = 3
+
+// at most this many fingerprints are tracked, the least recent are evicted
+const maxErrorStats = 1000
+
+// Fingerprint returns a stable id for err: errors with the same tree of types, created at the same place, have the same fingerprint
+// if there is no ctx.Error stack (e.g. sentinel errors), src (the call site of the log line) is used instead, never the message
+// since messages often contain ids
+func Fingerprint(err error, src string) string {
+	if err == nil {
+		return ""
+	}
+	h := sha256.New()
+	fingerprintTypes(h, err, 0)
+	var cerr ctx.Error
+	if errors.As(err, &cerr) && len(cerr.Stack) > 0 {
+		for i, frame := range cerr.Stack[:min(len(cerr.Stack), fingerprintFrames)].Frames() {
+			if i >= fingerprintFrames {
+				break // inlined
+			}
+			fmt.Fprintf(h, "%s\n", frame)
+		}
+	} else {
+		fmt.Fprintf(h, "at %s\n", src)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// write the types of the error tree, following both Unwrap() error and Unwrap() []error (e.g. ctx.Classify)
+func fingerprintTypes(w io.Writer, err error, depth int) {
+	fmt.Fprintf(w, "%d %T\n", depth, err)
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		if next := e.Unwrap(); next != nil {
+			fingerprintTypes(w, next, depth+1)
+		}
+	case interface{ Unwrap() []error }:
+		for _, next := range e.Unwrap() {
+			if next != nil {
+				fingerprintTypes(w, next, depth+1)
+			}
+		}
+	}
+}
+
+// ErrorStat is the aggregated count of the lines with the same error fingerprint, see TopErrors()
+type ErrorStat struct {
+	Fingerprint string    `json:"fingerprint"`
+	Error       string    `json:"error"` // the most recent
+	Src         string    `json:"src"`
+	Count       int64     `json:"count"`
+	Suppressed  int64     `json:"suppressed"` // lines not emitted because of Config.Errors
+	First       time.Time `json:"first"`
+	Last        time.Time `json:"last"`
+}
+
+type errorStat struct {
+	ErrorStat
+	elem    *list.Element // in errorStats.lru
+	min     int64         // current window
+	n       int           // lines emitted in the window
+	pending int           // lines suppressed since the last one emitted
+}
+
+var errorStats = struct {
+	m    sync.Mutex
+	byFP map[string]*errorStat
+	lru  *list.List // most recent first
+}{byFP: map[string]*errorStat{}, lru: list.New()}
+
+// count the error, and check if the line should be emitted given the limit per minute (0 is unlimited)
+// returns the number of lines suppressed since the last one emitted
+func seenError(fp string, err error, at Line, limit int) (ok bool, repeated int) {
+	errorStats.m.Lock()
+	defer errorStats.m.Unlock()
+	s := errorStats.byFP[fp]
+	if s == nil {
+		if len(errorStats.byFP) >= maxErrorStats {
+			oldest := errorStats.lru.Remove(errorStats.lru.Back()).(*errorStat)
+			delete(errorStats.byFP, oldest.Fingerprint)
+		}
+		s = &errorStat{ErrorStat: ErrorStat{Fingerprint: fp, First: at.Time}}
+		s.elem = errorStats.lru.PushFront(s)
+		errorStats.byFP[fp] = s
+	} else {
+		errorStats.lru.MoveToFront(s.elem)
+	}
+	s.Count++
+	s.Last = at.Time
+	s.Error = err.Error()
+	s.Src = at.Src
+	if limit <= 0 {
+		return true, 0
+	}
+	if min := at.Time.Unix() / 60; min != s.min {
+		s.min = min
+		s.n = 0
+	}
+	if s.n >= limit {
+		s.Suppressed++
+		s.pending++
+		return false, 0
+	}
+	s.n++
+	repeated = s.pending
+	s.pending = 0
+	return true, repeated
+}
+
+// TopErrors returns the stats of the n most frequent error fingerprints (all of them if n <= 0)
+func TopErrors(n int) []ErrorStat {
+	errorStats.m.Lock()
+	out := make([]ErrorStat, 0, len(errorStats.byFP))
+	for _, s := range errorStats.byFP {
+		out = append(out, s.ErrorStat)
+	}
+	errorStats.m.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Fingerprint < out[j].Fingerprint
+	})
+	if n > 0 && len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// ResetErrors clears the stats returned by TopErrors()
+func ResetErrors() {
+	errorStats.m.Lock()
+	defer errorStats.m.Unlock()
+	errorStats.byFP = map[string]*errorStat{}
+	errorStats.lru.Init()
+}
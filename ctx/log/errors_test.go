@@ -0,0 +1,65 @@
+package log_test
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/ctx/log"
+	"github.com/ohait/forego/test"
+)
+
+func TestFingerprint(t *testing.T) {
+	c := ctx.TODO()
+	mk := func(id int) error {
+		return ctx.NewErrorf(c, "user %d: %w", id, io.EOF) // same place, different message
+	}
+	a, b := log.Fingerprint(mk(1), "a.go:1"), log.Fingerprint(mk(2), "b.go:2") // the stack is used, not src
+	test.EqualsStr(t, a, b)
+	test.EqualsGo(t, 16, len(a))
+	test.Assert(t, a != log.Fingerprint(ctx.NewErrorf(c, "user %d: %w", 1, io.EOF), "a.go:1")) // different place
+
+	// no stack, the call site is used, never the message
+	test.EqualsStr(t, log.Fingerprint(fmt.Errorf("x: %w", io.EOF), "a.go:1"), log.Fingerprint(fmt.Errorf("y: %w", io.ErrUnexpectedEOF), "a.go:1"))
+	test.Assert(t, log.Fingerprint(io.EOF, "a.go:1") != log.Fingerprint(io.EOF, "a.go:2"))
+	test.Assert(t, log.Fingerprint(io.EOF, "a.go:1") != log.Fingerprint(fmt.Errorf("x: %w", io.EOF), "a.go:1"))
+	test.EqualsStr(t, "", log.Fingerprint(nil, "a.go:1"))
+
+	// the types of all the wrapped errors, also when there are many
+	test.Assert(t, log.Fingerprint(ctx.Classify(io.EOF, ctx.ErrNotFound), "a.go:1") !=
+		log.Fingerprint(ctx.Classify(&fs.PathError{Err: io.EOF}, ctx.ErrNotFound), "a.go:1"))
+}
+
+func TestErrorStats(t *testing.T) {
+	old := log.GetConfig()
+	t.Cleanup(func() { log.SetConfig(old) })
+	log.ResetErrors()
+	t.Cleanup(log.ResetErrors)
+
+	cfg := log.GetConfig()
+	cfg.Errors = 2
+	log.SetConfig(cfg)
+
+	logs := []log.Line{}
+	c := log.WithLogger(ctx.TODO(), func(at log.Line) {
+		logs = append(logs, at)
+	})
+	for i := 0; i < 5; i++ {
+		log.Errorf(c, "failed: %v", ctx.NewErrorf(c, "dependency %d down", i))
+	}
+	log.Warnf(c, "other: %v", io.EOF)
+	test.EqualsGo(t, 3, len(logs))
+	fp := log.Fingerprint(io.EOF, logs[2].Src)
+	test.EqualsJSON(t, fp, logs[2].Tags["fingerprint"])
+
+	top := log.TopErrors(0)
+	t.Logf("%+v", top)
+	test.EqualsGo(t, 2, len(top))
+	test.EqualsGo(t, int64(5), top[0].Count)
+	test.EqualsGo(t, int64(3), top[0].Suppressed)
+	test.EqualsStr(t, "dependency 4 down", top[0].Error)
+	test.EqualsStr(t, fp, top[1].Fingerprint)
+	test.EqualsGo(t, 1, len(log.TopErrors(1)))
+}
@@ -0,0 +1,54 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ohait/forego/ctx"
+)
+
+// Field is a key/value added to the `fields` object of a line, see F()
+type Field struct {
+	Key   string
+	Value any
+}
+
+// F creates a field, the value is marshalled only if the line is emitted:
+//
+//	log.Info(c, "paid", log.F("user", u.ID), log.F("amount", 3))
+//
+// unlike ctx.WithTag(), the field is only added to this line
+func F(key string, value any) Field {
+	return Field{key, value}
+}
+
+var fieldMarshaler atomic.Pointer[func(ctx.C, any) (ctx.JSON, error)]
+
+// SetFieldMarshaler replaces how field values are marshalled, the default is encoding/json
+// the `enc` package sets it to `enc.Marshal`, so the values are marshalled the same way as everywhere else
+func SetFieldMarshaler(f func(ctx.C, any) (ctx.JSON, error)) {
+	fieldMarshaler.Store(&f)
+}
+
+func (this Field) marshal(c ctx.C, tags *Tags) (j ctx.JSON) {
+	defer func() {
+		if r := recover(); r != nil { // logging must not panic
+			j, _ = json.Marshal(fmt.Sprintf("can't marshal %T: %v", this.Value, r))
+		}
+	}()
+	v := this.Value
+	if l, ok := v.(Loggable); ok {
+		v = l.LogAs(tags)
+	}
+	var err error
+	if f := fieldMarshaler.Load(); f != nil {
+		j, err = (*f)(c, v)
+	} else {
+		j, err = json.Marshal(v)
+	}
+	if err != nil {
+		j, _ = json.Marshal(fmt.Sprintf("can't marshal %T: %v", this.Value, err))
+	}
+	return j
+}
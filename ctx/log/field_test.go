@@ -0,0 +1,58 @@
+package log_test
+
+import (
+	"testing"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/ctx/log"
+	"github.com/ohait/forego/enc"
+	"github.com/ohait/forego/test"
+)
+
+type upper struct {
+	s     string
+	calls *int
+}
+
+func (this upper) MarshalNode(c ctx.C) (enc.Node, error) {
+	*this.calls++
+	return enc.String("[" + this.s + "]"), nil
+}
+
+func TestFields(t *testing.T) {
+	old := log.GetConfig()
+	t.Cleanup(func() { log.SetConfig(old) })
+
+	logs := []log.Line{}
+	c := log.WithLogger(ctx.TODO(), func(at log.Line) {
+		logs = append(logs, at)
+	})
+	calls := 0
+	log.Info(c, "100% done",
+		log.F("n", 3),
+		log.F("user", map[string]any{"id": 7}),
+		log.F("custom", upper{"x", &calls}),
+		log.F("secret", log.RedactedString("hunter2")),
+	)
+	test.EqualsGo(t, 1, len(logs))
+	t.Logf("%+v", logs[0])
+	test.EqualsStr(t, "100% done", logs[0].Message)
+	test.EqualsStr(t, "info", logs[0].Level)
+	test.EqualsStr(t, `3`, logs[0].Fields["n"].String())
+	test.EqualsStr(t, `{"id":7}`, logs[0].Fields["user"].String())
+	test.EqualsStr(t, `"[x]"`, logs[0].Fields["custom"].String())
+	test.EqualsStr(t, `"***"`, logs[0].Fields["secret"].String())
+	test.Nil(t, logs[0].Tags["n"])
+	test.EqualsGo(t, 1, calls)
+
+	// filtered lines don't marshal the fields
+	log.SetLevel(log.LevelInfo)
+	log.Debug(c, "skipped", log.F("custom", upper{"y", &calls}))
+	test.EqualsGo(t, 1, len(logs))
+	test.EqualsGo(t, 1, calls)
+
+	log.Warn(c, "bad", log.F("ch", make(chan int)))
+	last := logs[len(logs)-1]
+	test.EqualsStr(t, "bad", last.Message)
+	test.Contains(t, last.Fields["ch"].String(), "can't marshal chan int")
+}
@@ -0,0 +1,229 @@
+package log
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ohait/forego/ctx"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (this Level) String() string {
+	if this < 0 || int(this) >= len(levelNames) {
+		return fmt.Sprintf("level(%d)", int(this))
+	}
+	return levelNames[this]
+}
+
+// ParseLevel accepts the names used in Line.Level: "debug", "info", "warn" and "error"
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	if strings.EqualFold(s, "warning") {
+		return LevelWarn, nil
+	}
+	return LevelDebug, fmt.Errorf("unknown log level %q", s)
+}
+
+func (this Level) MarshalText() ([]byte, error) {
+	return []byte(this.String()), nil
+}
+
+func (this *Level) UnmarshalText(b []byte) error {
+	l, err := ParseLevel(string(b))
+	*this = l
+	return err
+}
+
+// Config controls which lines are emitted, it can be changed at runtime with SetConfig()
+type Config struct {
+	// lines below this level are dropped
+	Level Level `json:"level"`
+
+	// override Level for the lines whose Src matches the key, the longest key wins
+	// a key matches if it's a prefix of Src, or it follows a "/" in it, e.g. "forego/http/" or "myapp/storage/db.go"
+	// the versions of the modules are ignored, so "forego/http/" also matches ".../github.com/ohait/forego@v1.2.3/http/server.go"
+	Packages map[string]Level `json:"packages,omitempty"`
+
+	// at most this many debug and info lines per second for each call site whose Src matches the key (as in Packages)
+	// the next line emitted from the same call site has a "sampled" tag with the number of lines dropped
+	Sampling map[string]int `json:"sampling,omitempty"`
+
+	// at most this many lines per minute with the same error fingerprint (see Fingerprint()), 0 is unlimited
+	// the next line emitted with the same fingerprint has a "repeated" tag with the number of lines suppressed
+	Errors int `json:"errors,omitempty"`
+}
+
+type filter struct {
+	Config
+	packages []string // sorted by length, longest first
+	sampling []string
+	cache    sync.Map // src => *site
+}
+
+// a call site
+type site struct {
+	level  Level
+	sample int // lines per second, 0 if not sampled
+
+	m       sync.Mutex
+	sec     int64
+	n       int
+	dropped int
+}
+
+var current atomic.Pointer[filter]
+
+func init() {
+	SetConfig(Config{Level: LevelDebug})
+}
+
+// replace the global configuration
+func SetConfig(cfg Config) {
+	f := &filter{Config: cfg}
+	f.packages = byLength(cfg.Packages)
+	f.sampling = byLength(cfg.Sampling)
+	current.Store(f)
+}
+
+// return a copy of the global configuration
+func GetConfig() Config {
+	f := current.Load()
+	cfg := Config{
+		Level:    f.Level,
+		Errors:   f.Errors,
+		Packages: map[string]Level{},
+		Sampling: map[string]int{},
+	}
+	for k, v := range f.Packages {
+		cfg.Packages[k] = v
+	}
+	for k, v := range f.Sampling {
+		cfg.Sampling[k] = v
+	}
+	return cfg
+}
+
+// set the global minimum level
+func SetLevel(l Level) {
+	cfg := GetConfig()
+	cfg.Level = l
+	SetConfig(cfg)
+}
+
+type levelKey struct{}
+
+// lines logged with the returned context are filtered by the given level instead of the global configuration (but still sampled)
+// useful to debug a single request
+func WithLevel(c ctx.C, l Level) ctx.C {
+	return ctx.WithValue(c, levelKey{}, l)
+}
+
+// true if the filter depends on the Src of the line
+func (this *filter) bySrc() bool {
+	return len(this.packages) > 0 || len(this.sampling) > 0
+}
+
+// check if a line should be emitted, and if some lines were dropped by sampling before it
+func (this *filter) enabled(c ctx.C, level Level, src string) (ok bool, dropped int) {
+	min, hasMin := Level(0), false
+	if c != nil {
+		min, hasMin = c.Value(levelKey{}).(Level)
+	}
+	if !this.bySrc() {
+		if !hasMin {
+			min = this.Level
+		}
+		return level >= min, 0
+	}
+
+	s := this.site(src)
+	if !hasMin {
+		min = s.level
+	}
+	if level < min {
+		return false, 0
+	}
+	if s.sample <= 0 || level >= LevelWarn {
+		return true, 0
+	}
+	return s.take()
+}
+
+func (this *filter) site(src string) *site {
+	if s, ok := this.cache.Load(src); ok {
+		return s.(*site)
+	}
+	path := versionRE.ReplaceAllString(src, "")
+	s := &site{level: this.Level}
+	if k := match(this.packages, path); k != "" {
+		s.level = this.Packages[k]
+	}
+	if k := match(this.sampling, path); k != "" {
+		s.sample = this.Sampling[k]
+	}
+	v, _ := this.cache.LoadOrStore(src, s)
+	return v.(*site)
+}
+
+func (this *site) take() (bool, int) {
+	this.m.Lock()
+	defer this.m.Unlock()
+	now := time.Now().Unix()
+	if now != this.sec {
+		this.sec = now
+		this.n = 0
+	}
+	if this.n >= this.sample {
+		this.dropped++
+		return false, 0
+	}
+	this.n++
+	dropped := this.dropped
+	this.dropped = 0
+	return true, dropped
+}
+
+var versionRE = regexp.MustCompile(`@[^/]*`)
+
+// the first key (longest first) which matches path
+func match(keys []string, path string) string {
+	for _, k := range keys {
+		if strings.HasPrefix(path, k) || strings.Contains(path, "/"+k) {
+			return k
+		}
+	}
+	return ""
+}
+
+func byLength[T any](m map[string]T) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if len(out[i]) != len(out[j]) {
+			return len(out[i]) > len(out[j])
+		}
+		return out[i] < out[j]
+	})
+	return out
+}
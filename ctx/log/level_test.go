@@ -0,0 +1,55 @@
+package log_test
+
+import (
+	"testing"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/ctx/log"
+	"github.com/ohait/forego/test"
+)
+
+func TestLevel(t *testing.T) {
+	old := log.GetConfig()
+	t.Cleanup(func() { log.SetConfig(old) })
+
+	logs := []log.Line{}
+	c := log.WithLogger(ctx.TODO(), func(at log.Line) {
+		logs = append(logs, at)
+	})
+
+	log.SetLevel(log.LevelInfo)
+	log.Debugf(c, "dropped")
+	log.Infof(c, "kept")
+	test.EqualsGo(t, 1, len(logs))
+	test.EqualsGo(t, "kept", logs[0].Message)
+
+	// per package
+	log.SetConfig(log.Config{
+		Level:    log.LevelInfo,
+		Packages: map[string]log.Level{"ctx/log/": log.LevelError, "ctx/log/level_test.go": log.LevelWarn},
+	})
+	logs = nil
+	log.Infof(c, "dropped")
+	log.Warnf(c, "kept")
+	test.EqualsGo(t, 1, len(logs))
+
+	// per context
+	logs = nil
+	log.Debugf(log.WithLevel(c, log.LevelDebug), "kept")
+	test.EqualsGo(t, 1, len(logs))
+
+	// sampling
+	log.SetConfig(log.Config{
+		Sampling: map[string]int{"ctx/log/": 2},
+	})
+	logs = nil
+	for i := 0; i < 10; i++ {
+		log.Infof(c, "noisy %d", i)
+		log.Errorf(c, "never sampled %d", i)
+	}
+	test.EqualsGo(t, 12, len(logs))
+
+	l, err := log.ParseLevel("WARNING")
+	test.NoError(t, err)
+	test.EqualsGo(t, log.LevelWarn, l)
+}
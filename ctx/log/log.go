@@ -7,6 +7,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/ohait/forego/ctx"
@@ -20,6 +22,7 @@ type Line struct {
 	Time    time.Time `json:"time"`
 	Message string    `json:"message"`
 	Tags    Tags      `json:"tags,omitempty"`
+	Fields  Tags      `json:"fields,omitempty"` // see F()
 }
 
 // Tags mirrors the key-value metadata attached to a ctx.C. Values are stored as
@@ -60,18 +63,34 @@ func WithLoggerAndHelper(c ctx.C, logger func(Line), helper func()) ctx.C {
 	return context.WithValue(c, loggerKey{}, loggerValue{helper, logger})
 }
 
-var defaultLogger = func(at Line) {
-	j, _ := json.Marshal(at)
-	_, _ = fmt.Printf("%s\n", j)
+var defaultLogger atomic.Pointer[func(Line)]
+
+// FOREGO_LOG=console makes a Console the default logger
+func init() {
+	switch os.Getenv("FOREGO_LOG") {
+	case "console":
+		SetDefault(NewConsole().Log)
+	default:
+		SetDefault(func(at Line) {
+			j, _ := json.Marshal(at)
+			_, _ = fmt.Printf("%s\n", j)
+		})
+	}
+}
+
+// SetDefault replaces the logger used when the context has none, which prints each line to stdout synchronously
+// consider using a Sink, e.g. `log.SetDefault(log.NewSink(log.Stream{W: os.Stdout}, 10000, log.Block).Log)`
+func SetDefault(logger func(Line)) {
+	defaultLogger.Store(&logger)
 }
 
 func getLogger(c ctx.C) loggerValue {
 	if c == nil {
-		return loggerValue{func() {}, defaultLogger}
+		return loggerValue{func() {}, *defaultLogger.Load()}
 	}
 	logger, ok := c.Value(loggerKey{}).(loggerValue)
 	if !ok {
-		return loggerValue{func() {}, defaultLogger}
+		return loggerValue{func() {}, *defaultLogger.Load()}
 	}
 	return logger
 }
@@ -80,55 +99,131 @@ func getLogger(c ctx.C) loggerValue {
 func Errorf(c ctx.C, f string, args ...any) {
 	l := getLogger(c)
 	l.helper()
-	l.log(Line{
-		Src:   caller(1),
-		Level: "error",
-	}.formatf(c, f, args...))
+	l.logf(c, LevelError, nil, f, args...)
 }
 
 // Warnf records a warning-level log entry.
 func Warnf(c ctx.C, f string, args ...any) {
 	l := getLogger(c)
 	l.helper()
-	l.log(Line{
-		Src:   caller(1),
-		Level: "warn",
-	}.formatf(c, f, args...))
+	l.logf(c, LevelWarn, nil, f, args...)
 }
 
 // Infof records an info-level log entry.
 func Infof(c ctx.C, f string, args ...any) {
 	l := getLogger(c)
 	l.helper()
-	l.log(Line{
-		Src:   caller(1),
-		Level: "info",
-	}.formatf(c, f, args...))
+	l.logf(c, LevelInfo, nil, f, args...)
 }
 
 // Debugf records a debug-level log entry.
 func Debugf(c ctx.C, f string, args ...any) {
 	l := getLogger(c)
 	l.helper()
-	l.log(Line{
-		Src:   caller(1),
-		Level: "debug",
-	}.formatf(c, f, args...))
+	l.logf(c, LevelDebug, nil, f, args...)
+}
+
+// Error records an error-level log entry with the given fields, e.g. `log.Error(c, "payment failed", log.F("order", id))`
+func Error(c ctx.C, msg string, fields ...Field) {
+	l := getLogger(c)
+	l.helper()
+	l.logf(c, LevelError, fields, "%s", msg)
+}
+
+// Warn records a warning-level log entry with the given fields.
+func Warn(c ctx.C, msg string, fields ...Field) {
+	l := getLogger(c)
+	l.helper()
+	l.logf(c, LevelWarn, fields, "%s", msg)
+}
+
+// Info records an info-level log entry with the given fields.
+func Info(c ctx.C, msg string, fields ...Field) {
+	l := getLogger(c)
+	l.helper()
+	l.logf(c, LevelInfo, fields, "%s", msg)
+}
+
+// Debug records a debug-level log entry with the given fields.
+func Debug(c ctx.C, msg string, fields ...Field) {
+	l := getLogger(c)
+	l.helper()
+	l.logf(c, LevelDebug, fields, "%s", msg)
+}
+
+// filter (see Config), format and emit a line, must be called directly by the functions above
+func (l loggerValue) logf(c ctx.C, level Level, fields []Field, f string, args ...any) {
+	l.helper()
+	flt := current.Load()
+	src := ""
+	if flt.bySrc() {
+		src = caller(2)
+	}
+	ok, dropped := flt.enabled(c, level, src)
+	if !ok {
+		return
+	}
+	if src == "" {
+		src = caller(2)
+	}
+	at, err := Line{
+		Src:   src,
+		Level: level.String(),
+	}.formatf(c, f, args...)
+	if dropped > 0 {
+		at.Tags["sampled"], _ = json.Marshal(dropped)
+	}
+	if err != nil {
+		fp := Fingerprint(err, src)
+		ok, repeated := seenError(fp, err, at, flt.Errors)
+		if !ok {
+			return
+		}
+		at.Tags["fingerprint"], _ = json.Marshal(fp)
+		if repeated > 0 {
+			at.Tags["repeated"], _ = json.Marshal(repeated)
+		}
+	}
+	if len(fields) > 0 {
+		at.Fields = Tags{}
+		for _, f := range fields {
+			at.Fields[f.Key] = f.marshal(c, &at.Tags)
+		}
+	}
+	at.Tags.redact()
+	at.Fields.redact()
+	l.log(at)
 }
 
-// Log emits a preconstructed log line using the logger stored in c.
+// Log emits a preconstructed log line using the logger stored in c, filtered as the other lines (see Config).
 func (at Line) Log(c ctx.C) {
 	l := getLogger(c)
 	l.helper()
+	level, err := ParseLevel(at.Level)
+	if err != nil {
+		level = LevelInfo
+	}
+	ok, dropped := current.Load().enabled(c, level, at.Src)
+	if !ok {
+		return
+	}
+	if dropped > 0 {
+		if at.Tags == nil {
+			at.Tags = Tags{}
+		}
+		at.Tags["sampled"], _ = json.Marshal(dropped)
+	}
 	l.log(at)
 }
 
-func (at Line) formatf(c ctx.C, f string, args ...any) Line {
+// returns the first error in args, if any
+func (at Line) formatf(c ctx.C, f string, args ...any) (Line, error) {
 	if at.Time.IsZero() {
 		at.Time = time.Now()
 	}
 	at.Tags = tags(c)
 
+	var first error
 	errs := []map[string]any{}
 	for i := 0; i < len(args); i++ {
 		switch arg := args[i].(type) {
@@ -138,14 +233,26 @@ func (at Line) formatf(c ctx.C, f string, args ...any) Line {
 
 		case error:
 			// since errors can be wrapped, we need to unwrap them into ctx.Error to find the stack trace
+			if first == nil {
+				first = arg
+			}
 			m := map[string]any{"error": arg.Error()}
 			var err ctx.Error
 			if errors.As(arg, &err) {
-				m["stack"] = err.Stack
+				m["stack"] = err.Stack.Frames()
 				if err.C != nil {
 					m["tags"] = tags(err.C)
 				}
 			}
+			var remote ctx.RemoteError
+			if errors.As(arg, &remote) {
+				m["remote"] = map[string]any{
+					"error":    remote.Message,
+					"stack":    remote.Stack,
+					"tags":     remote.Tags,
+					"tracking": remote.Tracking,
+				}
+			}
 			errs = append(errs, m)
 		}
 	}
@@ -156,7 +263,7 @@ func (at Line) formatf(c ctx.C, f string, args ...any) Line {
 		at.Tags["error"], _ = json.Marshal(errs)
 	}
 	at.Message = fmt.Sprintf(f, args...)
-	return at
+	return at, first
 }
 
 func tags(c ctx.C) Tags {
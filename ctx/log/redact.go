@@ -0,0 +1,126 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ohait/forego/ctx"
+)
+
+// tags and fields (also nested in objects) with a key containing any of these are logged as "***"
+// keys are compared in lowercase, ignoring '-', '_' and '.', so "apikey" matches "X-Api-Key" and "api_key"
+var DefaultRedactKeys = []string{"password", "passwd", "secret", "token", "authorization", "cookie", "apikey", "privatekey"}
+
+var redactKeys atomic.Pointer[[]string]
+
+func init() {
+	SetRedactKeys(DefaultRedactKeys...)
+}
+
+// SetRedactKeys replaces the key deny list, see DefaultRedactKeys
+func SetRedactKeys(keys ...string) {
+	list := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if k = normalizeKey(k); k != "" {
+			list = append(list, k)
+		}
+	}
+	redactKeys.Store(&list)
+}
+
+// RedactKeys returns the current key deny list
+func RedactKeys() []string {
+	return append([]string{}, *redactKeys.Load()...)
+}
+
+// Redacted returns true if the values for the given key must not be logged
+func Redacted(key string) bool {
+	key = normalizeKey(key)
+	for _, k := range *redactKeys.Load() {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+var redactedJSON = ctx.JSON(`"***"`)
+
+var keyReplacer = strings.NewReplacer("-", "", "_", "", ".", "")
+
+func normalizeKey(k string) string {
+	return keyReplacer.Replace(strings.ToLower(k))
+}
+
+// replace the values of the keys in the deny list, including the ones nested in objects
+func (this Tags) redact() {
+	for k, j := range this {
+		this[k] = RedactTag(k, j)
+	}
+}
+
+// RedactTag returns the value of a tag (or a field) as it can be exported, e.g. by ctx/trace: "***" if the key is
+// in the deny list, or with the nested keys in the deny list redacted
+func RedactTag(key string, j ctx.JSON) ctx.JSON {
+	if Redacted(key) {
+		return redactedJSON
+	}
+	return redactJSON(j)
+}
+
+func redactJSON(j ctx.JSON) ctx.JSON {
+	if !bytes.ContainsRune(j, '{') {
+		return j // no keys
+	}
+	// cheap check before decoding: none of the keys appear anywhere
+	s := normalizeKey(string(j))
+	found := false
+	for _, k := range *redactKeys.Load() {
+		if strings.Contains(s, k) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return j
+	}
+	var v any
+	d := json.NewDecoder(bytes.NewReader(j))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return j
+	}
+	if !redactValue(v) {
+		return j
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return redactedJSON
+	}
+	return out
+}
+
+// returns true if anything was changed
+func redactValue(v any) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]any:
+		for k, x := range v {
+			if Redacted(k) {
+				v[k] = "***"
+				changed = true
+			} else if redactValue(x) {
+				changed = true
+			}
+		}
+	case []any:
+		for _, x := range v {
+			if redactValue(x) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
@@ -0,0 +1,36 @@
+package log_test
+
+import (
+	"testing"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/ctx/log"
+	"github.com/ohait/forego/test"
+)
+
+func TestRedact(t *testing.T) {
+	t.Cleanup(func() { log.SetRedactKeys(log.DefaultRedactKeys...) })
+
+	logs := []log.Line{}
+	c := log.WithLogger(ctx.TODO(), func(at log.Line) {
+		logs = append(logs, at)
+	})
+	c = ctx.WithTag(c, "Authorization", "Bearer xyz")
+	c = ctx.WithTag(c, "req", map[string]any{"headers": map[string]any{"X-Api-Key": "k"}, "n": 1})
+
+	log.Info(c, "login",
+		log.F("user", map[string]any{"name": "bob", "password": "hunter2", "list": []any{map[string]any{"refresh_token": "t"}}}),
+		log.F("session_token", "abc"),
+	)
+	test.EqualsGo(t, 1, len(logs))
+	l := logs[0]
+	test.EqualsStr(t, `"***"`, l.Tags["Authorization"].String())
+	test.EqualsStr(t, `{"headers":{"X-Api-Key":"***"},"n":1}`, l.Tags["req"].String())
+	test.EqualsStr(t, `{"list":[{"refresh_token":"***"}],"name":"bob","password":"***"}`, l.Fields["user"].String())
+	test.EqualsStr(t, `"***"`, l.Fields["session_token"].String())
+
+	log.SetRedactKeys("name")
+	test.EqualsGo(t, []string{"name"}, log.RedactKeys())
+	test.Assert(t, log.Redacted("user_Name"))
+	test.Assert(t, !log.Redacted("password"))
+}
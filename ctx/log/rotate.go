@@ -0,0 +1,110 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// an io.Writer appending to the file at Path, which is rotated when bigger than MaxSize, or older than Every
+// rotated files are renamed with the time of the rotation as suffix, e.g. "app.log.20240102-150405.000"
+type RotatingFile struct {
+	Path    string
+	MaxSize int64         // bytes, no limit if zero
+	Every   time.Duration // no limit if zero
+	Keep    int           // rotated files to keep, all if zero
+
+	m      sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+// the suffix added by rotate(), other files next to Path are never removed
+var rotatedSuffix = regexp.MustCompile(`^\.\d{8}-\d{6}\.\d{3}(-\d+)?$`)
+
+func (this *RotatingFile) Write(p []byte) (int, error) {
+	this.m.Lock()
+	defer this.m.Unlock()
+	if this.f == nil {
+		err := this.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+	if (this.MaxSize > 0 && this.size > 0 && this.size+int64(len(p)) > this.MaxSize) ||
+		(this.Every > 0 && time.Since(this.opened) >= this.Every) {
+		err := this.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := this.f.Write(p)
+	this.size += int64(n)
+	return n, err
+}
+
+func (this *RotatingFile) Close() error {
+	this.m.Lock()
+	defer this.m.Unlock()
+	if this.f == nil {
+		return nil
+	}
+	err := this.f.Close()
+	this.f = nil
+	return err
+}
+
+func (this *RotatingFile) open() error {
+	f, err := os.OpenFile(this.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	this.f = f
+	this.size = st.Size()
+	this.opened = time.Now()
+	return nil
+}
+
+func (this *RotatingFile) rotate() error {
+	err := this.f.Close()
+	this.f = nil
+	if err != nil {
+		return err
+	}
+	name := this.Path + "." + time.Now().Format("20060102-150405.000")
+	for i := 1; ; i++ { // more than one rotation in the same millisecond
+		if _, err := os.Stat(name); err != nil {
+			break
+		}
+		name = fmt.Sprintf("%s.%s-%d", this.Path, time.Now().Format("20060102-150405.000"), i)
+	}
+	err = os.Rename(this.Path, name)
+	if err != nil {
+		return err
+	}
+	if this.Keep > 0 {
+		matches, _ := filepath.Glob(this.Path + ".*")
+		old := matches[:0]
+		for _, m := range matches {
+			if rotatedSuffix.MatchString(m[len(this.Path):]) {
+				old = append(old, m)
+			}
+		}
+		sort.Strings(old) // oldest first
+		for len(old) > this.Keep {
+			_ = os.Remove(old[0])
+			old = old[1:]
+		}
+	}
+	return this.open()
+}
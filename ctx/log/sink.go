@@ -0,0 +1,133 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Writer is where a Sink writes the lines, in batches (see Stream, RotatingFile, HTTPWriter and Syslog)
+type Writer interface {
+	WriteLines(lines []Line) error
+}
+
+// what a Sink does when its buffer is full
+type Overflow int
+
+const (
+	Block Overflow = iota // wait for the writer, the caller is slowed down but no line is lost
+	Drop                  // drop the line, the number of lines dropped is logged later
+)
+
+// Sink buffers the lines and writes them in background, so logging doesn't add the latency of the writer
+//
+//	sink := log.NewSink(log.Stream{W: os.Stdout}, 10000, log.Drop)
+//	log.SetDefault(sink.Log)
+//
+// all the sinks are flushed by `log.Flush()`, which is called when `shutdown.Done()`
+type Sink struct {
+	w        Writer
+	ch       chan sinkItem
+	overflow Overflow
+	dropped  atomic.Int64
+}
+
+type sinkItem struct {
+	line Line
+	ack  chan struct{} // if set, it's a flush request
+}
+
+var sinks struct {
+	sync.Mutex
+	list []*Sink
+}
+
+// create a sink buffering up to size lines, and start writing them to w
+func NewSink(w Writer, size int, overflow Overflow) *Sink {
+	if size <= 0 {
+		size = 1024
+	}
+	this := &Sink{
+		w:        w,
+		ch:       make(chan sinkItem, size),
+		overflow: overflow,
+	}
+	sinks.Lock()
+	sinks.list = append(sinks.list, this)
+	sinks.Unlock()
+	go this.loop()
+	return this
+}
+
+// enqueue the line, can be used with `log.WithLogger()` and `log.SetDefault()`
+func (this *Sink) Log(l Line) {
+	if this.overflow == Block {
+		this.ch <- sinkItem{line: l}
+		return
+	}
+	select {
+	case this.ch <- sinkItem{line: l}:
+	default:
+		this.dropped.Add(1)
+	}
+}
+
+// wait until all the lines logged so far are written
+func (this *Sink) Flush() {
+	ack := make(chan struct{})
+	this.ch <- sinkItem{ack: ack}
+	<-ack
+}
+
+func (this *Sink) loop() {
+	batch := make([]Line, 0, 256)
+	var acks []chan struct{}
+	add := func(item sinkItem) {
+		if item.ack != nil {
+			acks = append(acks, item.ack)
+		} else {
+			batch = append(batch, item.line)
+		}
+	}
+	for item := range this.ch {
+		batch, acks = batch[:0], acks[:0]
+		add(item)
+	drain:
+		for len(batch) < cap(batch) {
+			select {
+			case item := <-this.ch:
+				add(item)
+			default:
+				break drain
+			}
+		}
+		if n := this.dropped.Swap(0); n > 0 {
+			batch = append(batch, Line{
+				Level:   "warn",
+				Src:     caller(0),
+				Time:    time.Now(),
+				Message: fmt.Sprintf("log sink full, %d lines dropped", n),
+			})
+		}
+		if len(batch) > 0 {
+			if err := this.w.WriteLines(batch); err != nil {
+				fmt.Fprintf(os.Stderr, "log sink: can't write %d lines: %v\n", len(batch), err)
+			}
+		}
+		for _, ack := range acks {
+			close(ack)
+		}
+	}
+}
+
+// flush all the sinks, called by the shutdown package when the shutdown is done
+func Flush() {
+	sinks.Lock()
+	list := append([]*Sink{}, sinks.list...)
+	sinks.Unlock()
+	for _, s := range list {
+		s.Flush()
+	}
+}
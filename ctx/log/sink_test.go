@@ -0,0 +1,101 @@
+package log_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/ctx/log"
+	"github.com/ohait/forego/test"
+)
+
+type memWriter struct {
+	m     sync.Mutex
+	gate  chan struct{}
+	lines []log.Line
+}
+
+func (this *memWriter) WriteLines(lines []log.Line) error {
+	if this.gate != nil {
+		<-this.gate
+	}
+	this.m.Lock()
+	defer this.m.Unlock()
+	this.lines = append(this.lines, lines...)
+	return nil
+}
+
+func TestSink(t *testing.T) {
+	w := &memWriter{}
+	sink := log.NewSink(w, 10, log.Block)
+	c := log.WithLogger(ctx.TODO(), sink.Log)
+	for i := 0; i < 100; i++ {
+		log.Infof(c, "line %d", i)
+	}
+	sink.Flush()
+	test.EqualsGo(t, 100, len(w.lines))
+	test.EqualsGo(t, "line 99", w.lines[99].Message)
+}
+
+func TestSinkDrop(t *testing.T) {
+	w := &memWriter{gate: make(chan struct{})}
+	sink := log.NewSink(w, 5, log.Drop)
+	c := log.WithLogger(ctx.TODO(), sink.Log)
+	for i := 0; i < 100; i++ {
+		log.Infof(c, "line %d", i) // never blocks
+	}
+	close(w.gate)
+	log.Flush()
+	last := w.lines[len(w.lines)-1]
+	t.Logf("%d lines, last: %s", len(w.lines), last.Message)
+	test.Assert(t, len(w.lines) < 100)
+	test.Contains(t, last.Message, "lines dropped")
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	f := &log.RotatingFile{
+		Path:    filepath.Join(dir, "app.log"),
+		MaxSize: 100,
+		Keep:    2,
+	}
+	defer f.Close()
+	w := log.Stream{W: f, Format: func(l log.Line) string { return l.Message }}
+	for i := 0; i < 10; i++ {
+		test.NoError(t, w.WriteLines([]log.Line{{Message: strings.Repeat("x", 40)}}))
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "app.log*"))
+	t.Logf("files: %v", files)
+	test.EqualsGo(t, 3, len(files)) // app.log and 2 rotated
+	st, err := os.Stat(f.Path)
+	test.NoError(t, err)
+	test.Assert(t, st.Size() <= 100)
+}
+
+func TestRotatingFileKeepsOthers(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	for _, name := range []string{path + ".bak", path + ".lock", path + ".20240102-150405.000.gz"} {
+		test.NoError(t, os.WriteFile(name, []byte("keep"), 0o644))
+	}
+	f := &log.RotatingFile{
+		Path:    path,
+		MaxSize: 100,
+		Keep:    1,
+	}
+	defer f.Close()
+	w := log.Stream{W: f, Format: func(l log.Line) string { return l.Message }}
+	for i := 0; i < 10; i++ {
+		test.NoError(t, w.WriteLines([]log.Line{{Message: strings.Repeat("x", 40)}}))
+	}
+	files, _ := filepath.Glob(path + "*")
+	t.Logf("files: %v", files)
+	test.EqualsGo(t, 5, len(files)) // app.log, 1 rotated and the 3 unrelated
+	for _, name := range []string{path + ".bak", path + ".lock", path + ".20240102-150405.000.gz"} {
+		_, err := os.Stat(name)
+		test.NoError(t, err)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !windows && !plan9
+
+package log
+
+import (
+	"log/syslog"
+)
+
+// write the lines as JSON to syslog, with the priority of their level
+//
+//	w, err := syslog.New(syslog.LOG_DAEMON, "myapp")
+//	sink := log.NewSink(log.Syslog{W: w}, 10000, log.Drop)
+type Syslog struct {
+	W *syslog.Writer
+}
+
+func (this Syslog) WriteLines(lines []Line) error {
+	for _, l := range lines {
+		var err error
+		switch l.Level {
+		case "error":
+			err = this.W.Err(l.JSON())
+		case "warn":
+			err = this.W.Warning(l.JSON())
+		case "info":
+			err = this.W.Info(l.JSON())
+		default:
+			err = this.W.Debug(l.JSON())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// write the lines to W, one per line, as JSON unless Format is set
+// W can be os.Stdout, a RotatingFile, or any io.Writer
+type Stream struct {
+	W      io.Writer
+	Format func(Line) string
+}
+
+func (this Stream) WriteLines(lines []Line) error {
+	buf := &bytes.Buffer{}
+	for _, l := range lines {
+		if this.Format != nil {
+			buf.WriteString(this.Format(l))
+		} else {
+			buf.WriteString(l.JSON())
+		}
+		buf.WriteByte('\n')
+	}
+	_, err := this.W.Write(buf.Bytes())
+	return err
+}
+
+// POST the lines as NDJSON to URL, e.g. to a log collector
+type HTTPWriter struct {
+	URL     string
+	Headers map[string]string // e.g. for authentication
+	Timeout time.Duration     // default 10s
+}
+
+func (this HTTPWriter) WriteLines(lines []Line) error {
+	buf := &bytes.Buffer{}
+	err := Stream{W: buf}.WriteLines(lines)
+	if err != nil {
+		return err
+	}
+	timeout := this.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	c, cf := context.WithTimeout(context.Background(), timeout)
+	defer cf()
+	req, err := http.NewRequestWithContext(c, "POST", this.URL, buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	for k, v := range this.Headers {
+		req.Header.Set(k, v)
+	}
+	res, err := http.DefaultClient.Do(req) // not forego http.Client, which would log
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("POST %s: %s", this.URL, res.Status)
+	}
+	return nil
+}
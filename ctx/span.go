@@ -1,8 +1,16 @@
 package ctx
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -61,13 +69,153 @@ func WithNextTracking(c C) C {
 	return WithTracking(c, k.(string))
 }
 
-// Span is work in progress
+// the role of a span, used by tracing backends to build service maps
+type SpanKind int
+
+const (
+	SpanInternal SpanKind = iota // the default
+	SpanServer                   // handles a request from a remote caller, e.g. http.Server
+	SpanClient                   // sends a request to a remote service, e.g. http.Client
+)
+
+// SpanData is what a SpanExporter receives when a span ends
+type SpanData struct {
+	TraceID  string // 32 hex digits, derived from the tracking id
+	SpanID   string // 16 hex digits
+	ParentID string // empty for root spans
+	Name     string
+	Kind     SpanKind
+	Tracking string
+	Start    time.Time
+	End      time.Time
+	Tags     map[string]JSON // the tags of the context when the span ended, the last value wins
+	Err      error           // the cause the span was ended with, if any (but not context.Canceled)
+}
+
+// receives the spans as they end, must be safe for concurrent use
+type SpanExporter interface {
+	Export(SpanData)
+}
+
+type spanExporterKey struct{}
+
+// spans created from the returned context, and its children, are sent to exp
+func WithSpanExporter(c C, exp SpanExporter) C {
+	return WithValue(c, spanExporterKey{}, exp)
+}
+
+// SpanContext identifies a span across services, as in a W3C `traceparent`
+type SpanContext struct {
+	TraceID string
+	SpanID  string
+}
+
+type spanKey struct{}
+
+type span struct {
+	SpanData
+	exp  SpanExporter
+	once sync.Once
+}
+
+// GetSpan returns the current span, or the trace id derived from the tracking id (with no span id) if there is no span
+func GetSpan(c C) SpanContext {
+	switch s := c.Value(spanKey{}).(type) {
+	case *span:
+		return SpanContext{s.TraceID, s.SpanID}
+	case SpanContext:
+		return s
+	}
+	if t := GetTracking(c); t != "" {
+		return SpanContext{TraceID: traceID(t)}
+	}
+	return SpanContext{}
+}
+
+// spans created from the returned context will be children of the given remote span, e.g. from a `traceparent` header
+func WithRemoteSpan(c C, sc SpanContext) C {
+	return WithValue(c, spanKey{}, sc)
+}
+
+// Span starts a new span as a child of the current one (if any), with a new step of the tracking id
+// the returned CancelFunc ends the span, with the given cause as error, and cancels the context:
+//
+//	c, cf := ctx.Span(c, "load")
+//	err := load(c)
+//	cf(err)
+//
+// spans are only recorded if there is an exporter, see WithSpanExporter()
 func Span(c C, name string) (C, CancelFunc) {
-	// TODO add opentelemetry support
+	return SpanWithKind(c, SpanInternal, name)
+}
+
+// like Span, but for a span which is not SpanInternal
+func SpanWithKind(c C, kind SpanKind, name string) (C, CancelFunc) {
 	c, cf := WithCancel(c)
-	k := c.Value(trackingNext{})
-	if k == nil {
-		k = ""
+	parent := GetSpan(c)
+	c = WithNextTracking(c)
+	exp, _ := c.Value(spanExporterKey{}).(SpanExporter)
+	if exp == nil {
+		return c, cf
+	}
+	s := &span{
+		SpanData: SpanData{
+			TraceID:  parent.TraceID,
+			SpanID:   NewSpanID(),
+			ParentID: parent.SpanID,
+			Name:     name,
+			Kind:     kind,
+			Tracking: GetTracking(c),
+			Start:    time.Now(),
+		},
+		exp: exp,
+	}
+	if s.TraceID == "" {
+		s.TraceID = traceID(s.Tracking)
 	}
-	return WithTracking(c, k.(string)), cf
+	c = WithValue(c, spanKey{}, s)
+	return c, func(cause error) {
+		EndSpan(c, cause)
+		cf(cause)
+	}
+}
+
+// EndSpan ends the current span of c (if any, and only once) without cancelling c
+// useful when the work is done, but the context is still used (e.g. a reply sent asynchronously)
+func EndSpan(c C, err error) {
+	s, ok := c.Value(spanKey{}).(*span)
+	if !ok {
+		return
+	}
+	s.once.Do(func() {
+		d := s.SpanData
+		d.End = time.Now()
+		if err != nil && !errors.Is(err, context.Canceled) {
+			d.Err = err
+		}
+		d.Tags = map[string]JSON{}
+		_ = RangeTag(c, func(k string, j JSON) error {
+			d.Tags[k] = j
+			return nil
+		})
+		s.exp.Export(d)
+	})
+}
+
+// the W3C trace-id of a tracking id: the root uuid if it is one, or a hash of the root
+func traceID(tracking string) string {
+	root, _, _ := strings.Cut(tracking, ".")
+	t := strings.ToLower(strings.ReplaceAll(root, "-", ""))
+	if _, err := hex.DecodeString(t); err == nil && len(t) == 32 {
+		return t
+	}
+	h := sha256.Sum256([]byte(root))
+	return hex.EncodeToString(h[:16])
+}
+
+// return a random W3C span-id, 16 hex digits
+func NewSpanID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
 }
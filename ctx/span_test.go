@@ -0,0 +1,49 @@
+package ctx_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/ctx/trace"
+	"github.com/ohait/forego/test"
+)
+
+func TestSpan(t *testing.T) {
+	mem := &trace.Memory{}
+	c := test.Context(t)
+	c = ctx.WithTracking(c, "0af76519-16cd-43dd-8448-eb211c80319c")
+	c = ctx.WithSpanExporter(c, mem)
+
+	c1, cf1 := ctx.Span(c, "parent")
+	c1 = ctx.WithTag(c1, "user", 42)
+	c2, cf2 := ctx.Span(c1, "child")
+	test.EqualsGo(t, "0af76519-16cd-43dd-8448-eb211c80319c.1.1", ctx.GetTracking(c2))
+	cf2(errors.New("boom"))
+	test.Assert(t, c2.Err() != nil)
+	cf1(nil)
+	cf1(errors.New("ignored, already ended"))
+
+	spans := mem.Spans()
+	test.EqualsGo(t, 2, len(spans))
+	child, parent := spans[0], spans[1]
+	test.EqualsGo(t, "child", child.Name)
+	test.EqualsGo(t, "0af7651916cd43dd8448eb211c80319c", parent.TraceID)
+	test.EqualsGo(t, parent.TraceID, child.TraceID)
+	test.EqualsGo(t, parent.SpanID, child.ParentID)
+	test.EqualsGo(t, "", parent.ParentID)
+	test.EqualsGo(t, "boom", child.Err.Error())
+	test.Nil(t, parent.Err)
+	test.EqualsGo(t, "42", string(child.Tags["user"]))
+	test.Assert(t, !child.End.Before(child.Start))
+
+	// remote parent
+	mem.Reset()
+	c3 := ctx.WithRemoteSpan(c, ctx.SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7"})
+	_, cf3 := ctx.Span(c3, "remote")
+	cf3(nil)
+	s := mem.Find("remote")
+	test.NotNil(t, s)
+	test.EqualsGo(t, "4bf92f3577b34da6a3ce929d0e0e4736", s.TraceID)
+	test.EqualsGo(t, "00f067aa0ba902b7", s.ParentID)
+}
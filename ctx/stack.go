@@ -0,0 +1,90 @@
+package ctx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+)
+
+// Stack holds the program counters captured when a ctx.Error is created, they are symbolized only when needed (see Frames())
+type Stack []uintptr
+
+// the maximum value for SetStackDepth()
+const MaxStackDepth = 128
+
+var stackDepth atomic.Int32
+
+var noStack atomic.Pointer[[]error]
+
+func init() {
+	stackDepth.Store(64)
+}
+
+// SetStackDepth changes how many frames are captured by NewErrorf() and WrapError(), 0 disables the capture (default is 64, max is MaxStackDepth)
+func SetStackDepth(n int) {
+	stackDepth.Store(int32(max(0, min(n, MaxStackDepth))))
+}
+
+// SkipStack disables the stack capture for the errors wrapping any of the given sentinels, e.g. for errors which are expected and handled
+// the errors are still wrapped in ctx.Error, with the context
+func SkipStack(sentinels ...error) {
+	for {
+		old := noStack.Load()
+		var list []error
+		if old != nil {
+			list = append(list, *old...)
+		}
+		list = append(list, sentinels...)
+		if noStack.CompareAndSwap(old, &list) {
+			return
+		}
+	}
+}
+
+func skipStack(err error) bool {
+	list := noStack.Load()
+	if list == nil {
+		return false
+	}
+	for _, s := range *list {
+		if errors.Is(err, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// capture the stack, skipping the given number of frames above the caller
+func stack(skip int) Stack {
+	n := int(stackDepth.Load())
+	if n == 0 {
+		return nil
+	}
+	var buf [MaxStackDepth]uintptr
+	n = runtime.Callers(skip+2, buf[:n]) // +0 runtime.Callers, +1 this
+	return append(Stack(nil), buf[:n]...)
+}
+
+// Frames returns the stack as "file:line", one for each frame (including the inlined ones)
+func (this Stack) Frames() []string {
+	if len(this) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(this))
+	frames := runtime.CallersFrames(this)
+	for {
+		f, more := frames.Next()
+		if f.File != "" {
+			out = append(out, fmt.Sprintf("%s:%d", f.File, f.Line))
+		}
+		if !more {
+			return out
+		}
+	}
+}
+
+func (this Stack) MarshalJSON() ([]byte, error) {
+	return json.Marshal(this.Frames())
+}
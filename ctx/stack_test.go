@@ -1 +1,49 @@
 package ctx_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/test"
+)
+
+var errExpected = errors.New("expected")
+
+func TestStack(t *testing.T) {
+	c := ctx.TODO()
+	var cerr ctx.Error
+
+	err := ctx.NewErrorf(c, "boom")
+	test.Assert(t, errors.As(err, &cerr))
+	frames := cerr.Stack.Frames()
+	t.Logf("frames: %v", frames)
+	test.Assert(t, strings.Contains(frames[0], "ctx/stack_test.go:"))
+	test.Assert(t, len(frames) > 1)
+
+	t.Cleanup(func() { ctx.SetStackDepth(64) })
+	ctx.SetStackDepth(1)
+	_ = errors.As(ctx.NewErrorf(c, "boom"), &cerr)
+	test.EqualsGo(t, 1, len(cerr.Stack))
+	test.Assert(t, strings.Contains(cerr.Stack.Frames()[0], "ctx/stack_test.go:"))
+
+	ctx.SetStackDepth(0)
+	_ = errors.As(ctx.NewErrorf(c, "boom"), &cerr)
+	test.EqualsGo(t, 0, len(cerr.Stack))
+	ctx.SetStackDepth(64)
+
+	ctx.SkipStack(errExpected)
+	err = ctx.NewErrorf(c, "not found: %w", errExpected)
+	test.Assert(t, errors.As(err, &cerr))
+	test.Assert(t, errors.Is(err, errExpected))
+	test.EqualsGo(t, 0, len(cerr.Stack))
+	test.Assert(t, cerr.C != nil)
+}
+
+func BenchmarkNewErrorf(b *testing.B) {
+	c := ctx.TODO()
+	for i := 0; i < b.N; i++ {
+		_ = ctx.NewErrorf(c, "boom")
+	}
+}
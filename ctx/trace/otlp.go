@@ -0,0 +1,235 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/ctx/log"
+	"github.com/ohait/forego/shutdown"
+)
+
+// sends the spans to an OpenTelemetry collector, using OTLP/HTTP with JSON encoding
+//
+// spans are sent in batches, every few seconds or when a batch is full, and when the shutdown starts
+// if the collector can't keep up, spans are dropped
+type OTLP struct {
+	URL     string            // e.g. "http://localhost:4318/v1/traces"
+	Service string            // the `service.name` resource attribute
+	Headers map[string]string // e.g. for authentication
+
+	MaxBatch int           // default 512 (also if zero)
+	Every    time.Duration // default 5s (also if zero)
+
+	m     sync.Mutex
+	queue []ctx.SpanData
+	kick  chan struct{}
+}
+
+var _ ctx.SpanExporter = &OTLP{}
+
+// create an OTLP exporter and start sending the spans in background until c is done or the shutdown starts
+func NewOTLP(c ctx.C, url, service string) *OTLP {
+	this := &OTLP{
+		URL:     url,
+		Service: service,
+		kick:    make(chan struct{}, 1),
+	}
+	go this.loop(c)
+	return this
+}
+
+func (this *OTLP) maxBatch() int {
+	if this.MaxBatch > 0 {
+		return this.MaxBatch
+	}
+	return 512
+}
+
+func (this *OTLP) every() time.Duration {
+	if this.Every > 0 {
+		return this.Every
+	}
+	return 5 * time.Second
+}
+
+func (this *OTLP) Export(s ctx.SpanData) {
+	this.m.Lock()
+	defer this.m.Unlock()
+	if len(this.queue) >= 10*this.maxBatch() {
+		return // dropped
+	}
+	this.queue = append(this.queue, s)
+	if len(this.queue) >= this.maxBatch() {
+		select {
+		case this.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (this *OTLP) loop(c ctx.C) {
+	defer shutdown.Hold().Release()
+	t := time.NewTicker(this.every())
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-this.kick:
+		case <-c.Done():
+			this.flushAll(ctx.Detach(c))
+			return
+		case <-shutdown.Started():
+			this.flushAll(c)
+			return
+		}
+		this.flushAll(c)
+	}
+}
+
+func (this *OTLP) flushAll(c ctx.C) {
+	for {
+		n, err := this.Flush(c)
+		if err != nil {
+			log.Warnf(c, "otlp: can't send %d spans: %v", n, err)
+			return
+		}
+		if n < this.maxBatch() {
+			return
+		}
+	}
+}
+
+// send up to MaxBatch spans, returns how many were sent (or dropped because of an error)
+func (this *OTLP) Flush(c ctx.C) (int, error) {
+	this.m.Lock()
+	batch := this.queue
+	if len(batch) > this.maxBatch() {
+		batch = batch[:this.maxBatch()]
+	}
+	this.queue = this.queue[len(batch):]
+	this.m.Unlock()
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	j, err := json.Marshal(this.request(batch))
+	if err != nil {
+		return len(batch), ctx.NewErrorf(c, "can't marshal spans: %w", err)
+	}
+	c, cf := ctx.WithTimeout(c, 10*time.Second)
+	defer cf()
+	req, err := http.NewRequestWithContext(c, "POST", this.URL, bytes.NewReader(j))
+	if err != nil {
+		return len(batch), ctx.NewErrorf(c, "can't create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range this.Headers {
+		req.Header.Set(k, v)
+	}
+	res, err := http.DefaultClient.Do(req) // not forego http.Client, which would create more spans
+	if err != nil {
+		return len(batch), ctx.NewErrorf(c, "can't send spans: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return len(batch), ctx.NewErrorf(c, "can't send spans: %s", res.Status)
+	}
+	return len(batch), nil
+}
+
+// see https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttr `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpSpan struct {
+	TraceID      string     `json:"traceId"`
+	SpanID       string     `json:"spanId"`
+	ParentSpanID string     `json:"parentSpanId,omitempty"`
+	Name         string     `json:"name"`
+	Kind         int        `json:"kind"`
+	Start        string     `json:"startTimeUnixNano"`
+	End          string     `json:"endTimeUnixNano"`
+	Attributes   []otlpAttr `json:"attributes,omitempty"`
+	Status       struct {
+		Code    int    `json:"code,omitempty"` // 0 unset, 2 error
+		Message string `json:"message,omitempty"`
+	} `json:"status"`
+}
+
+type otlpAttr struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+func (this *OTLP) request(batch []ctx.SpanData) otlpRequest {
+	rs := otlpResourceSpans{}
+	rs.Resource.Attributes = []otlpAttr{{"service.name", map[string]any{"stringValue": this.Service}}}
+	ss := otlpScopeSpans{}
+	ss.Scope.Name = "github.com/ohait/forego"
+	for _, s := range batch {
+		out := otlpSpan{
+			TraceID:      s.TraceID,
+			SpanID:       s.SpanID,
+			ParentSpanID: s.ParentID,
+			Name:         s.Name,
+			Kind:         int(s.Kind) + 1, // 1 internal, 2 server, 3 client
+			Start:        strconv.FormatInt(s.Start.UnixNano(), 10),
+			End:          strconv.FormatInt(s.End.UnixNano(), 10),
+		}
+		for _, k := range sortedKeys(s.Tags) {
+			out.Attributes = append(out.Attributes, otlpAttr{k, attrValue(log.RedactTag(k, s.Tags[k]))})
+		}
+		if s.Err != nil {
+			out.Status.Code = 2
+			out.Status.Message = s.Err.Error()
+		}
+		ss.Spans = append(ss.Spans, out)
+	}
+	rs.ScopeSpans = []otlpScopeSpans{ss}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{rs}}
+}
+
+// convert a tag into an OTLP AnyValue, objects and arrays are kept as JSON strings
+func attrValue(j ctx.JSON) map[string]any {
+	var v any
+	d := json.NewDecoder(bytes.NewReader(j))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return map[string]any{"stringValue": string(j)}
+	}
+	switch v := v.(type) {
+	case string:
+		return map[string]any{"stringValue": v}
+	case bool:
+		return map[string]any{"boolValue": v}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return map[string]any{"intValue": fmt.Sprint(i)}
+		}
+		f, _ := v.Float64()
+		return map[string]any{"doubleValue": f}
+	default:
+		return map[string]any{"stringValue": string(j)}
+	}
+}
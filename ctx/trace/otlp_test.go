@@ -0,0 +1,84 @@
+package trace_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/ctx/trace"
+	"github.com/ohait/forego/test"
+)
+
+func TestOTLP(t *testing.T) {
+	c := test.Context(t)
+
+	got := make(chan []byte, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		test.EqualsGo(t, "/v1/traces", r.URL.Path)
+		test.EqualsGo(t, "secret", r.Header.Get("X-Token"))
+		j, _ := io.ReadAll(r.Body)
+		got <- j
+	}))
+	defer srv.Close()
+
+	exp := trace.NewOTLP(c, srv.URL+"/v1/traces", "test-svc")
+	exp.Headers = map[string]string{"X-Token": "secret"}
+
+	c = ctx.WithSpanExporter(c, exp)
+	c = ctx.WithTracking(c, "0af76519-16cd-43dd-8448-eb211c80319c")
+	c1, cf1 := ctx.SpanWithKind(c, ctx.SpanServer, "parent")
+	c1 = ctx.WithTag(c1, "password", "hunter2")
+	c1 = ctx.WithTag(c1, "user", map[string]string{"name": "bob", "api_key": "k3y"})
+	c2, cf2 := ctx.Span(ctx.WithTag(c1, "n", 3), "child")
+	_ = c2
+	cf2(errors.New("boom"))
+	cf1(nil)
+
+	n, err := exp.Flush(c)
+	test.NoError(t, err)
+	test.EqualsGo(t, 2, n)
+
+	select {
+	case j := <-got:
+		t.Logf("%s", j)
+		test.Contains(t, string(j), `{"key":"service.name","value":{"stringValue":"test-svc"}}`)
+		test.Contains(t, string(j), `"traceId":"0af7651916cd43dd8448eb211c80319c"`)
+		test.Contains(t, string(j), `"name":"child","kind":1`)
+		test.Contains(t, string(j), `"name":"parent","kind":2`)
+		test.Contains(t, string(j), `{"key":"n","value":{"intValue":"3"}}`)
+		test.Contains(t, string(j), `"status":{"code":2,"message":"boom"}`)
+		// same redaction as the logs
+		test.Contains(t, string(j), `{"key":"password","value":{"stringValue":"***"}}`)
+		test.NotContains(t, string(j), "hunter2")
+		test.NotContains(t, string(j), "k3y")
+		test.Contains(t, string(j), "bob")
+	case <-time.After(time.Second):
+		t.Fatalf("nothing received")
+	}
+}
+
+func TestOTLPZero(t *testing.T) {
+	c := test.Context(t)
+
+	got := make(chan []byte, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		j, _ := io.ReadAll(r.Body)
+		got <- j
+	}))
+	defer srv.Close()
+
+	exp := &trace.OTLP{URL: srv.URL} // no MaxBatch
+	c = ctx.WithSpanExporter(c, exp)
+	for range 3 {
+		_, cf := ctx.Span(c, "x")
+		cf(nil)
+	}
+	n, err := exp.Flush(c)
+	test.NoError(t, err)
+	test.EqualsGo(t, 3, n)
+	test.Contains(t, string(<-got), `"name":"x"`)
+}
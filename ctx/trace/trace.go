@@ -0,0 +1,55 @@
+// Package trace provides exporters for the spans created with `ctx.Span()`
+package trace
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/ohait/forego/ctx"
+)
+
+// keeps all the spans in memory, useful for tests
+type Memory struct {
+	m     sync.Mutex
+	spans []ctx.SpanData
+}
+
+var _ ctx.SpanExporter = &Memory{}
+
+func (this *Memory) Export(s ctx.SpanData) {
+	this.m.Lock()
+	defer this.m.Unlock()
+	this.spans = append(this.spans, s)
+}
+
+// the spans exported so far, in order of completion
+func (this *Memory) Spans() []ctx.SpanData {
+	this.m.Lock()
+	defer this.m.Unlock()
+	return append([]ctx.SpanData{}, this.spans...)
+}
+
+// the first span with the given name, or nil
+func (this *Memory) Find(name string) *ctx.SpanData {
+	for _, s := range this.Spans() {
+		if s.Name == name {
+			return &s
+		}
+	}
+	return nil
+}
+
+func (this *Memory) Reset() {
+	this.m.Lock()
+	defer this.m.Unlock()
+	this.spans = nil
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -25,6 +25,9 @@ type Handler struct {
 
 	Debugf func(c ctx.C, f string, args ...any)
 
+	// if true, Marshal() replaces the value of the fields with a `redact` struct tag with "***", used for logging
+	Redact bool
+
 	path path
 }
 
@@ -135,6 +138,9 @@ func (this Handler) unmarshal(c ctx.C, from Node, v reflect.Value) error {
 		}
 		*into = t
 		return nil
+	case *ctx.Error:
+		// ctx can't import enc, so ctx.Error can only implement json.Unmarshaler
+		return into.UnmarshalJSON(JSON{}.Encode(c, from))
 	case json.Unmarshaler:
 		if this.Debugf != nil {
 			this.Debugf(c, "is %T", into)
@@ -290,6 +296,10 @@ func (this Handler) MarshalStruct(c ctx.C, in any, pairs ...Pair) (Pairs, error)
 		if tag.Skip {
 			continue
 		}
+		if tag.Redact && this.Redact {
+			out = append(out, Pair{tag.Name, String("***")})
+			continue
+		}
 		fv := v.Field(i)
 		fn, err := this.marshalValue(c, fv)
 		if err != nil {
@@ -338,6 +348,9 @@ func Marshal(c ctx.C, in any) (Node, error) {
 
 func (this Handler) Marshal(c ctx.C, in any) (Node, error) {
 	// log.Warnf(c, "OHA: %T %v", in, in)
+	if err, ok := in.(*ctx.Error); ok && err == nil {
+		return Nil{}, nil // MarshalJSON has a value receiver
+	}
 	switch in := in.(type) {
 	case nil:
 		return Nil{}, nil
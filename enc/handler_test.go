@@ -392,3 +392,30 @@ func TestRegistry(t *testing.T) {
 	test.NoError(t, err)
 	test.EqualsGo(t, N(42), i)
 }
+
+func TestRedact(t *testing.T) {
+	c := test.Context(t)
+
+	type creds struct {
+		User string `json:"user"`
+		Pass string `json:"pass" redact:"true"`
+		Hint string `json:"hint" redact:"false"`
+	}
+	type x struct {
+		Creds []creds `json:"creds"`
+	}
+	in := x{Creds: []creds{{"bob", "hunter2", "pet"}}}
+	j := enc.MustMarshalJSON(c, in)
+	test.ContainsJSON(t, j, "hunter2") // only when logging
+
+	n, err := enc.Handler{Redact: true}.Marshal(c, in)
+	test.NoError(t, err)
+	j = enc.JSON{}.Encode(c, n)
+	test.EqualsStr(t, `{"creds":[{"user":"bob","pass":"***","hint":"pet"}]}`, string(j))
+
+	type bad struct {
+		Pass string `redact:"yes"`
+	}
+	_, err = enc.Handler{Redact: true}.Marshal(c, bad{})
+	test.Error(t, err)
+}
@@ -0,0 +1,18 @@
+package enc
+
+import (
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/ctx/log"
+)
+
+// log.F() values are marshalled via Marshal(), so Marshaler types are logged as they are sent,
+// and the fields with a `redact` struct tag are not logged (ctx/log can't import enc, since enc logs)
+func init() {
+	log.SetFieldMarshaler(func(c ctx.C, v any) (ctx.JSON, error) {
+		n, err := Handler{Redact: true}.Marshal(c, v)
+		if err != nil {
+			return nil, err
+		}
+		return JSON{}.Encode(c, n), nil
+	})
+}
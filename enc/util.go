@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/ohait/forego/ctx"
@@ -91,10 +92,28 @@ type Tag struct {
 	Name      string
 	OmitEmpty bool
 	Skip      bool
+	Redact    bool // `redact:"true"`, see Handler.Redact
+}
+
+// parse the `redact` tag of the field: false if missing, otherwise the value must be a bool (e.g. `redact:"true"`)
+func ParseRedact(f reflect.StructField) (bool, error) {
+	v, ok := f.Tag.Lookup("redact")
+	if !ok {
+		return false, nil
+	}
+	redact, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid redact tag on %s: %q", f.Name, v)
+	}
+	return redact, nil
 }
 
 func parseTag(tag reflect.StructField) (out Tag, err error) {
 	out.Name = tag.Name
+	out.Redact, err = ParseRedact(tag)
+	if err != nil {
+		return out, err
+	}
 
 	names := []string{tag.Tag.Get("name")}
 	skip := false
@@ -1,7 +1,9 @@
 package http
 
 import (
-	"io"
+	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/ohait/forego/api"
 	"github.com/ohait/forego/api/openapi"
@@ -39,31 +41,62 @@ func (s *Server) RegisterStreamingAPI(c ctx.C, path string, obj Streamable) (*op
 	if err != nil {
 		return nil, err
 	}
-	f := func(c ctx.C, in io.Reader, out func(ctx.C, any) error) error {
-		req := &api.JSON{}
-		if in != nil {
-			err := req.ReadFrom(c, in)
+	f := func(r *http.Request, out func(ctx.C, any) error) error {
+		c := r.Context()
+		get := false
+		switch r.Method {
+		case "GET", "HEAD": // e.g. EventSource, if not queryable the body is read as for POST
+			get = handler.Queryable()
+		}
+		req := newRequest(r, path)
+		if get {
+			// no body, the `in` fields are in the query string
+		} else if r.Body != nil {
+			err := req.ReadFrom(c, r.Body)
 			if err != nil {
 				return ctx.NewErrorf(c, "can't read request body: %v", err)
 			}
 		} else {
 			log.Infof(c, "can/t get body: %v", err)
 		}
-		// TODO auth
+		uid, err := authenticate(c, s.Auth, r)
+		if err != nil {
+			return err
+		}
+
+		req.UID = uid
 
-		obj, err := handler.Recv(c, req)
+		var obj Streamable
+		if get {
+			obj, err = handler.RecvQuery(c, req)
+		} else {
+			obj, err = handler.Recv(c, req)
+		}
 		if err != nil {
-			return NewErrorf(c, 400, "%v", err) // receive errors are always 4xx (TODO how to handle 403?)
+			return recvError(c, err)
+		}
+		if id := lastEventID(r); id > 0 {
+			if res, ok := obj.(api.Resumable); ok {
+				err = res.Resume(c, id)
+				if err != nil {
+					return err
+				}
+			}
 		}
-		return obj.Stream(c, out)
+		return api.Stream(c, obj, out)
 	}
 
 	if path == "" {
 		return nil, ctx.NewErrorf(c, "no path to register for %T", obj)
 	}
+	err = checkPathParams(c, path, handler.Params(api.InPath))
+	if err != nil {
+		return nil, ctx.NewErrorf(c, "can't register %T: %w", obj, err)
+	}
 
 	log.Debugf(c, "registering to %q", path)
-	s.handleStream(path, f)
+	_, resumable := obj.(api.Resumable)
+	s.handleStream(path, resumable, handler.IsSafe(), f)
 	return handler.UpdateOpenAPI(c, s.OpenAPI, path)
 }
 
@@ -72,10 +105,22 @@ func (s *Server) RegisterAPI(c ctx.C, path string, obj Doable) (*openapi.PathIte
 	if err != nil {
 		return nil, err
 	}
-	f := func(r *Request) (any, error) {
+	headers := handler.Params(api.InHeader) // part of the idempotency fingerprint
+	f := func(w ResponseWriter, r *Request) (any, error) {
 		c := r.Context()
-		req := &api.JSON{}
-		if r.Body != nil {
+		get := false
+		switch r.Method {
+		case "GET", "HEAD":
+			if !handler.AllowGet() {
+				w.Header().Set("Allow", "POST")
+				return nil, NewErrorf(c, 405, "%s not allowed for %T", r.Method, obj)
+			}
+			get = true
+		}
+		req := newRequest(r, path)
+		if get {
+			// no body, the `in` fields are in the query string
+		} else if r.Body != nil {
 			err := req.ReadFrom(c, r.Body)
 			if err != nil {
 				return nil, ctx.NewErrorf(c, "can't read request body: %v", err)
@@ -84,33 +129,112 @@ func (s *Server) RegisterAPI(c ctx.C, path string, obj Doable) (*openapi.PathIte
 		} else {
 			log.Infof(c, "can/t get body: %v", err)
 		}
-		// TODO auth
-
-		obj, err := handler.Recv(c, req)
-		if err != nil {
-			return nil, NewErrorf(c, 400, "%v", err) // receive errors are always 4xx (TODO how to handle 403?)
-		}
-		err = obj.Do(c)
+		uid, err := authenticate(c, s.Auth, r)
 		if err != nil {
 			return nil, err
 		}
-		// log.Debugf(c, "API %+v", obj)
+		req.UID = uid
 
-		res := &api.JSON{}
-		err = handler.Send(c, obj, res)
-		if err != nil {
-			return nil, err
+		run := func() ([]byte, error) {
+			var obj Doable
+			if get {
+				obj, err = handler.RecvQuery(c, req)
+			} else {
+				obj, err = handler.Recv(c, req)
+			}
+			if err != nil {
+				return nil, recvError(c, err)
+			}
+			err = api.Do(c, obj)
+			if err != nil {
+				return nil, err
+			}
+			// log.Debugf(c, "API %+v", obj)
+
+			if cacheable, ok := obj.(api.Cacheable); ok && get {
+				cc, etag := cacheable.CacheControl(c)
+				if cc != "" {
+					w.Header().Set("Cache-Control", cc)
+				}
+				if etag != "" {
+					if !strings.HasSuffix(etag, `"`) {
+						etag = `"` + etag + `"`
+					}
+					w.Header().Set("ETag", etag)
+				}
+			}
+
+			res := &api.JSON{}
+			err = handler.Send(c, obj, res)
+			if err != nil {
+				return nil, err
+			}
+			out := enc.JSON{}.Encode(c, res.Data)
+			log.Debug(c, "API response", log.F("out", handler.LogOut(obj)))
+			return out, nil
+		}
+		if key := r.Header.Get(IdempotencyKey); key != "" && s.Idempotency != nil && !get {
+			return s.idempotent(c, w, r, req, key, headers, run)
 		}
-		out := enc.JSON{}.Encode(c, res.Data)
-		log.Debugf(c, "API response %s", out)
-		return out, nil
+		return run()
 	}
 
 	if path == "" {
 		return nil, ctx.NewErrorf(c, "no path to register for %T", obj)
 	}
+	err = checkPathParams(c, path, handler.Params(api.InPath))
+	if err != nil {
+		return nil, ctx.NewErrorf(c, "can't register %T: %w", obj, err)
+	}
 
 	log.Debugf(c, "registering to %q", path)
 	s.handleRequest(path, f)
+	s.apis.Store(path, true)
 	return handler.UpdateOpenAPI(c, s.OpenAPI, path)
 }
+
+// creates an api.JSON with the headers, query and path parameters of the request
+func newRequest(r *http.Request, pattern string) *api.JSON {
+	req := &api.JSON{
+		Header: r.Header,
+		Query:  r.URL.Query(),
+	}
+	for _, name := range pathParams(pattern) {
+		if req.Path == nil {
+			req.Path = map[string]string{}
+		}
+		req.Path[name] = r.PathValue(name)
+	}
+	return req
+}
+
+// check that each `api:"path,name"` field has a {name} wildcard in the pattern, otherwise it would always be empty
+func checkPathParams(c ctx.C, pattern string, names []string) error {
+	wildcards := pathParams(pattern)
+	for _, name := range names {
+		if !slices.Contains(wildcards, name) {
+			return ctx.NewErrorf(c, "path parameter %q is not a wildcard of %q", name, pattern)
+		}
+	}
+	return nil
+}
+
+// returns the names of the wildcards in the pattern, e.g. "/items/{id}" returns ["id"]
+func pathParams(pattern string) []string {
+	var out []string
+	for {
+		_, rest, ok := strings.Cut(pattern, "{")
+		if !ok {
+			return out
+		}
+		name, rest, ok := strings.Cut(rest, "}")
+		if !ok {
+			return out
+		}
+		name = strings.TrimSuffix(name, "...")
+		if name != "$" && name != "" {
+			out = append(out, name)
+		}
+		pattern = rest
+	}
+}
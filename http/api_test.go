@@ -2,12 +2,19 @@ package http_test
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	gohttp "net/http"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ohait/forego/api"
 	"github.com/ohait/forego/ctx"
 	"github.com/ohait/forego/ctx/log"
+	"github.com/ohait/forego/enc"
 	"github.com/ohait/forego/http"
 	"github.com/ohait/forego/test"
 )
@@ -100,3 +107,518 @@ func (this *ResponseWriter) Write(b []byte) (int, error) {
 func (this *ResponseWriter) WriteHeader(code int) {
 	this.Code = code
 }
+
+type Lookup struct {
+	Key string `api:"path,key"`
+	Ver int    `api:"query,v"`
+	RID string `api:"header,X-Request-Id"`
+
+	Out string `api:"out" json:"out"`
+}
+
+func (this *Lookup) Do(c ctx.C) error {
+	this.Out = fmt.Sprintf("%s@%d/%s", this.Key, this.Ver, this.RID)
+	return nil
+}
+
+func TestAPIParams(t *testing.T) {
+	c := test.Context(t)
+
+	s := http.NewServer(c)
+	pi, err := s.RegisterAPI(c, "/lookup/{key}", &Lookup{})
+	test.NoError(t, err)
+	test.EqualsGo(t, 3, len(pi.Parameters))
+
+	_, err = s.RegisterAPI(c, "/lookup2/{kye}", &Lookup{}) // typo
+	test.Error(t, err)
+	test.Contains(t, err.Error(), `"key"`)
+	_, err = s.RegisterAPI(c, "/lookup3", &Lookup{})
+	test.Error(t, err)
+
+	addr, err := s.Listen(c, "127.0.0.1:0")
+	test.NoError(t, err)
+
+	cli := http.Client{
+		BaseUrl: &url.URL{Scheme: "http", Host: addr.String()},
+	}
+	op := Lookup{Key: "a b", Ver: 3, RID: "r1"}
+	err = cli.API(c, &op, "/lookup/{key}")
+	test.NoError(t, err)
+	test.EqualsGo(t, "a b@3/r1", op.Out)
+}
+
+type Version struct {
+	Name string `api:"in" json:"name"`
+	Out  string `api:"out" json:"out"`
+}
+
+func (this *Version) Do(c ctx.C) error {
+	this.Out = "v1 " + this.Name
+	return nil
+}
+
+func (this *Version) Safe() bool {
+	return true
+}
+
+func (this *Version) CacheControl(c ctx.C) (string, string) {
+	return "public, max-age=60", "v1-" + this.Name
+}
+
+type Upload struct {
+	Data map[string]any `api:"in" json:"data"`
+}
+
+func (this *Upload) Do(c ctx.C) error {
+	return nil
+}
+
+func TestAPIGet(t *testing.T) {
+	c := test.Context(t)
+
+	s := http.NewServer(c)
+	s.MustRegisterAPI(c, "/version", &Version{})
+	s.MustRegisterAPI(c, "/upload", &Upload{})
+	s.MustRegisterAPI(c, "/fetch", &Fetch{})
+	test.NotNil(t, s.OpenAPI.Paths["/version"].Get)
+	test.EqualsGo(t, "name", s.OpenAPI.Paths["/version"].Get.Parameters[0].Name)
+	test.Nil(t, s.OpenAPI.Paths["/upload"].Get)
+	test.Nil(t, s.OpenAPI.Paths["/fetch"].Get) // not Safe
+
+	// GET and POST don't share the responses
+	delete(s.OpenAPI.Paths["/version"].Get.Responses, "400")
+	test.NotNil(t, s.OpenAPI.Paths["/version"].Post.Responses["400"].Content)
+
+	get := func(path string, etag string) *ResponseWriter {
+		req, err := http.NewRequest(c, "GET", path, nil)
+		test.NoError(t, err)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := &ResponseWriter{}
+		s.Mux().ServeHTTP(w, req)
+		t.Logf("res: %d %s %v", w.Code, w.Buf.String(), w.Header())
+		return w
+	}
+
+	w := get("/version?name=foo", "")
+	test.EqualsGo(t, 200, w.Code)
+	test.Contains(t, w.Buf.String(), "v1 foo")
+	test.EqualsGo(t, "public, max-age=60", w.Header().Get("Cache-Control"))
+	test.EqualsGo(t, `"v1-foo"`, w.Header().Get("ETag"))
+
+	w = get("/version?name=foo", `"v1-foo"`)
+	test.EqualsGo(t, 304, w.Code)
+	test.EqualsStr(t, "", w.Buf.String())
+
+	w = get("/upload", "")
+	test.EqualsGo(t, 405, w.Code)
+	test.EqualsGo(t, "POST", w.Header().Get("Allow"))
+
+	w = get("/fetch?id=ok", "")
+	test.EqualsGo(t, 405, w.Code)
+}
+
+type Resize struct {
+	Width  int `api:"in" json:"width" validate:"min=1,max=4096"`
+	Height int `api:"in" json:"height" validate:"min=1,max=4096"`
+}
+
+func (this *Resize) Do(c ctx.C) error {
+	return nil
+}
+
+func TestAPIValidation(t *testing.T) {
+	c := test.Context(t)
+
+	s := http.NewServer(c)
+	s.MustRegisterAPI(c, "/resize", &Resize{})
+
+	req, err := http.NewRequest(c, "POST", "/resize", bytes.NewBufferString(`{"width":-1,"height":5000}`))
+	test.NoError(t, err)
+	w := &ResponseWriter{}
+	s.Mux().ServeHTTP(w, req)
+	t.Logf("res: %d %s", w.Code, w.Buf.String())
+	test.EqualsGo(t, 400, w.Code)
+	test.Contains(t, w.Buf.String(), `{"path":"width","message":"must be at least 1"}`)
+	test.Contains(t, w.Buf.String(), `{"path":"height","message":"must be at most 4096"}`)
+}
+
+type Fetch struct {
+	ID  string `api:"in,required" json:"id"`
+	Out string `api:"out" json:"out"`
+}
+
+func (this *Fetch) Do(c ctx.C) error {
+	switch this.ID {
+	case "ok":
+		this.Out = "found"
+		return nil
+	case "boom":
+		return ctx.NewErrorf(c, "secret internal detail")
+	case "gone":
+		return ctx.NewErrorf(c, "no item %q: %w", this.ID, ctx.ErrNotFound)
+	case "busy":
+		return ctx.Classify(ctx.NewErrorf(c, "busy"), ctx.ErrUnavailable, ctx.ErrRetryable)
+	case "upstream":
+		return ctx.NewErrorf(c, "upstream: %w", http.ErrCircuitOpen)
+	default:
+		return api.NewError(404, "item_not_found", "no item %q", this.ID)
+	}
+}
+
+func (this *Fetch) Errors() []api.Error {
+	return []api.Error{
+		{Status: 404, Code: "item_not_found"},
+	}
+}
+
+func TestAPIProblem(t *testing.T) {
+	c := test.Context(t)
+
+	s := http.NewServer(c)
+	pi := s.MustRegisterAPI(c, "/fetch", &Fetch{})
+	test.Contains(t, pi.Responses["404"].Description, "item_not_found")
+	test.NotNil(t, pi.Responses["404"].Content["application/problem+json"].Schema)
+	test.Contains(t, pi.Responses["400"].Description, api.CodeInvalid)
+
+	post := func(body string) *ResponseWriter {
+		req, err := http.NewRequest(c, "POST", "/fetch", bytes.NewBufferString(body))
+		test.NoError(t, err)
+		w := &ResponseWriter{}
+		s.Mux().ServeHTTP(w, req)
+		t.Logf("res: %d %s", w.Code, w.Buf.String())
+		return w
+	}
+
+	w := post(`{"id":"x"}`)
+	test.EqualsGo(t, 404, w.Code)
+	test.EqualsGo(t, "application/problem+json", w.Header().Get("Content-Type"))
+	test.Contains(t, w.Buf.String(), `"code":"item_not_found"`)
+	test.Contains(t, w.Buf.String(), `"detail":"no item \"x\""`)
+
+	w = post(`{}`)
+	test.EqualsGo(t, 400, w.Code)
+	test.Contains(t, w.Buf.String(), `"code":"invalid"`)
+	test.Contains(t, w.Buf.String(), `{"path":"id","message":"required"}`)
+
+	w = post(`{"id":"boom"}`)
+	test.EqualsGo(t, 500, w.Code)
+	test.Contains(t, w.Buf.String(), `"code":"internal_server_error"`)
+	test.Assert(t, !strings.Contains(w.Buf.String(), "secret"))
+
+	addr, err := s.Listen(c, "127.0.0.1:0")
+	test.NoError(t, err)
+	cli := http.Client{
+		BaseUrl: &url.URL{Scheme: "http", Host: addr.String()},
+	}
+	err = cli.API(c, &Fetch{ID: "y"}, "/fetch")
+	var ae api.Error
+	test.Assert(t, errors.As(err, &ae))
+	test.EqualsGo(t, 404, ae.Status)
+	test.EqualsGo(t, "item_not_found", ae.Code)
+	test.EqualsGo(t, 404, http.ErrorCode(err, 0))
+	test.Assert(t, errors.Is(err, ctx.ErrNotFound))
+
+	w = post(`{"id":"gone"}`)
+	test.EqualsGo(t, 404, w.Code)
+	test.Contains(t, w.Buf.String(), `"code":"not_found"`)
+	err = cli.API(c, &Fetch{ID: "gone"}, "/fetch")
+	test.Assert(t, errors.Is(err, ctx.ErrNotFound))
+	test.Assert(t, !errors.Is(err, ctx.ErrRetryable))
+
+	// both are 503, but only the retryable one has a Retry-After
+	w = post(`{"id":"busy"}`)
+	test.EqualsGo(t, 503, w.Code)
+	test.EqualsGo(t, "1", w.Header().Get("Retry-After"))
+	err = cli.API(c, &Fetch{ID: "busy"}, "/fetch")
+	test.Assert(t, errors.Is(err, ctx.ErrUnavailable))
+	test.Assert(t, errors.Is(err, ctx.ErrRetryable))
+
+	w = post(`{"id":"upstream"}`)
+	test.EqualsGo(t, 503, w.Code)
+	test.EqualsGo(t, "", w.Header().Get("Retry-After"))
+	err = cli.API(c, &Fetch{ID: "upstream"}, "/fetch")
+	test.Assert(t, errors.Is(err, ctx.ErrUnavailable))
+	test.Assert(t, !errors.Is(err, ctx.ErrRetryable))
+}
+
+type Count struct {
+	To      int `api:"in" json:"to"`
+	FailAt  int `api:"in" json:"fail_at"`
+	WaitMS  int `api:"in" json:"wait_ms"`  // before starting
+	PauseMS int `api:"in" json:"pause_ms"` // after each emit
+
+	from int
+}
+
+func (this *Count) Safe() bool {
+	return true
+}
+
+func (this *Count) Resume(c ctx.C, lastID int) error {
+	this.from = lastID
+	return nil
+}
+
+func (this *Count) Stream(c ctx.C, emit func(ctx.C, any) error) error {
+	time.Sleep(time.Duration(this.WaitMS) * time.Millisecond)
+	if this.To < 0 {
+		return api.NewError(400, "negative", "can't count to %d", this.To)
+	}
+	for i := this.from + 1; i <= this.To; i++ {
+		if i == this.FailAt {
+			return api.NewError(409, "changed", "data changed while streaming")
+		}
+		err := emit(c, map[string]int{"i": i})
+		if err != nil {
+			return err
+		}
+		time.Sleep(time.Duration(this.PauseMS) * time.Millisecond)
+	}
+	return nil
+}
+
+func TestClientStream(t *testing.T) {
+	c := test.Context(t)
+
+	s := http.NewServer(c)
+	s.MustRegisterStreamingAPI(c, "/count", &Count{})
+	addr, err := s.Listen(c, "127.0.0.1:0")
+	test.NoError(t, err)
+	cli := http.Client{
+		BaseUrl: &url.URL{Scheme: "http", Host: addr.String()},
+	}
+
+	var got []int
+	err = cli.Stream(c, &Count{To: 3}, "/count", func(c ctx.C, n enc.Node) error {
+		var line struct {
+			I int `json:"i"`
+		}
+		err := enc.Unmarshal(c, n, &line)
+		got = append(got, line.I)
+		return err
+	})
+	test.NoError(t, err)
+	test.EqualsGo(t, []int{1, 2, 3}, got)
+
+	got = nil
+	err = cli.Stream(c, &Count{To: 100}, "/count", func(c ctx.C, n enc.Node) error {
+		got = append(got, 0)
+		if len(got) == 2 {
+			return io.EOF
+		}
+		return nil
+	})
+	test.Assert(t, errors.Is(err, io.EOF))
+	test.EqualsGo(t, 2, len(got))
+
+	err = cli.Stream(c, &Count{To: -1}, "/count", func(c ctx.C, n enc.Node) error {
+		return nil
+	})
+	var ae api.Error
+	test.Assert(t, errors.As(err, &ae))
+	test.EqualsGo(t, "negative", ae.Code)
+
+	got = nil
+	err = cli.Stream(c, &Count{To: 5, FailAt: 3}, "/count", func(c ctx.C, n enc.Node) error {
+		got = append(got, 0)
+		return nil
+	})
+	test.Assert(t, errors.As(err, &ae))
+	test.EqualsGo(t, "changed", ae.Code)
+	test.EqualsGo(t, 2, len(got))
+}
+
+// emits objects which look like the error of a failed stream
+type Alerts struct{}
+
+func (this *Alerts) Stream(c ctx.C, emit func(ctx.C, any) error) error {
+	for i := range 3 {
+		err := emit(c, map[string]any{"error": map[string]any{"status": 500, "title": fmt.Sprintf("disk %d full", i)}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestClientStreamErrorData(t *testing.T) {
+	c := test.Context(t)
+
+	s := http.NewServer(c)
+	s.MustRegisterStreamingAPI(c, "/alerts", &Alerts{})
+	addr, err := s.Listen(c, "127.0.0.1:0")
+	test.NoError(t, err)
+	cli := http.Client{
+		BaseUrl: &url.URL{Scheme: "http", Host: addr.String()},
+	}
+
+	var got []string
+	err = cli.Stream(c, &Alerts{}, "/alerts", func(c ctx.C, n enc.Node) error {
+		var line struct {
+			Error api.Problem `json:"error"`
+		}
+		err := enc.Unmarshal(c, n, &line)
+		got = append(got, line.Error.Title)
+		return err
+	})
+	test.NoError(t, err) // the trailer is "ok", so they are data
+	test.EqualsGo(t, []string{"disk 0 full", "disk 1 full", "disk 2 full"}, got)
+}
+
+func TestStreamNotSafe(t *testing.T) {
+	c := test.Context(t)
+
+	s := http.NewServer(c)
+	s.MustRegisterStreamingAPI(c, "/alerts", &Alerts{}) // queryable, but not api.Safe
+	test.Nil(t, s.OpenAPI.Paths["/alerts"].Get)
+	test.NotNil(t, s.OpenAPI.Paths["/alerts"].Post)
+
+	addr, err := s.Listen(c, "127.0.0.1:0")
+	test.NoError(t, err)
+
+	for _, method := range []string{"GET", "POST"} {
+		req, err := http.NewRequest(c, method, "http://"+addr.String()+"/alerts", nil)
+		test.NoError(t, err)
+		req.Header.Set("Accept", "text/event-stream")
+		res, err := gohttp.DefaultClient.Do(req)
+		test.NoError(t, err)
+		body, err := io.ReadAll(res.Body)
+		test.NoError(t, err)
+		res.Body.Close()
+		t.Logf("%s => %d %s", method, res.StatusCode, body)
+		if method == "GET" {
+			test.EqualsGo(t, 405, res.StatusCode)
+			test.EqualsGo(t, "POST", res.Header.Get("Allow"))
+		} else {
+			test.EqualsGo(t, 200, res.StatusCode)
+			test.Contains(t, string(body), "disk 2 full")
+		}
+	}
+}
+
+func TestStreamTrailer(t *testing.T) {
+	c := test.Context(t)
+
+	s := http.NewServer(c)
+	s.MustRegisterStreamingAPI(c, "/count", &Count{})
+	addr, err := s.Listen(c, "127.0.0.1:0")
+	test.NoError(t, err)
+
+	for _, tc := range []struct {
+		body   string
+		status string
+	}{
+		{`{"to":2}`, "ok"},
+		{`{"to":5,"fail_at":3}`, "409"},
+	} {
+		res, err := gohttp.Post("http://"+addr.String()+"/count", "application/json", strings.NewReader(tc.body))
+		test.NoError(t, err)
+		body, err := io.ReadAll(res.Body)
+		test.NoError(t, err)
+		res.Body.Close()
+		t.Logf("%s => %s %v", tc.body, body, res.Trailer)
+		test.EqualsGo(t, 200, res.StatusCode)
+		test.EqualsGo(t, "application/x-ndjson", res.Header.Get("Content-Type"))
+		test.EqualsGo(t, tc.status, res.Trailer.Get(http.StreamStatus))
+	}
+}
+
+func TestSSE(t *testing.T) {
+	c := test.Context(t)
+
+	s := http.NewServer(c)
+	s.SSEHeartbeat = 10 * time.Millisecond
+	s.MustRegisterStreamingAPI(c, "/count", &Count{})
+	addr, err := s.Listen(c, "127.0.0.1:0")
+	test.NoError(t, err)
+
+	get := func(query string, lastID string) (*gohttp.Response, string) {
+		req, err := http.NewRequest(c, "GET", "http://"+addr.String()+"/count?"+query, nil)
+		test.NoError(t, err)
+		req.Header.Set("Accept", "text/event-stream")
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		res, err := gohttp.DefaultClient.Do(req)
+		test.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		test.NoError(t, err)
+		t.Logf("%s => %d %q", query, res.StatusCode, body)
+		return res, string(body)
+	}
+
+	res, body := get("to=2", "")
+	test.EqualsGo(t, 200, res.StatusCode)
+	test.EqualsGo(t, "text/event-stream", res.Header.Get("Content-Type"))
+	test.EqualsStr(t, "id: 1\ndata: {\"i\":1}\n\nid: 2\ndata: {\"i\":2}\n\nevent: end\ndata: {}\n\n", body)
+
+	// resumed
+	_, body = get("to=4", "2")
+	test.EqualsStr(t, "id: 3\ndata: {\"i\":3}\n\nid: 4\ndata: {\"i\":4}\n\nevent: end\ndata: {}\n\n", body)
+
+	// heartbeat, only once streaming
+	_, body = get("to=2&wait_ms=30&pause_ms=30", "")
+	test.Assert(t, strings.HasPrefix(body, "id: 1\n"))
+	test.Contains(t, body, ": heartbeat\n\nid: 2\n")
+
+	// a slow op failing before the first event still gets the status
+	res, body = get("to=-1&wait_ms=50", "")
+	test.EqualsGo(t, 400, res.StatusCode)
+	test.Contains(t, body, `"code":"negative"`)
+
+	// errors
+	_, body = get("to=3&fail_at=2", "")
+	test.Contains(t, body, "id: 1\ndata: {\"i\":1}\n\nevent: error\ndata: {")
+	test.Contains(t, body, `"code":"changed"`)
+
+	res, _ = get("to=-1", "")
+	test.EqualsGo(t, 400, res.StatusCode)
+
+	res, _ = get("to=0", "")
+	test.EqualsGo(t, 204, res.StatusCode)
+}
+
+// not Resumable, and not queryable
+type Echo struct {
+	Items []string `api:"in" json:"items"`
+}
+
+func (this *Echo) Safe() bool {
+	return true
+}
+
+func (this *Echo) Stream(c ctx.C, emit func(ctx.C, any) error) error {
+	for _, s := range append([]string{"start"}, this.Items...) {
+		err := emit(c, s)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestSSENotResumable(t *testing.T) {
+	c := test.Context(t)
+
+	s := http.NewServer(c)
+	s.MustRegisterStreamingAPI(c, "/echo", &Echo{})
+	addr, err := s.Listen(c, "127.0.0.1:0")
+	test.NoError(t, err)
+
+	req, err := http.NewRequest(c, "GET", "http://"+addr.String()+"/echo", nil)
+	test.NoError(t, err)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Last-Event-ID", "5")
+	res, err := gohttp.DefaultClient.Do(req)
+	test.NoError(t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	test.NoError(t, err)
+
+	// the stream starts over, and so do the ids
+	test.EqualsGo(t, 200, res.StatusCode)
+	test.EqualsStr(t, "id: 1\ndata: \"start\"\n\nevent: end\ndata: {}\n\n", string(body))
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ohait/forego/api"
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/enc"
+)
+
+// Authenticator extracts the identity of the caller from a request.
+//
+// It returns a nil node if the request carries no credentials, and an error if the credentials are invalid.
+// Errors are reported as 401, unless they are an http.Error with a different code (e.g. 403).
+type Authenticator interface {
+	Authenticate(c ctx.C, r *http.Request) (enc.Node, error)
+}
+
+// AuthenticatorFunc allows a plain function to be used as an Authenticator
+type AuthenticatorFunc func(c ctx.C, r *http.Request) (enc.Node, error)
+
+func (f AuthenticatorFunc) Authenticate(c ctx.C, r *http.Request) (enc.Node, error) {
+	return f(c, r)
+}
+
+// calls the given authenticator (if any), and wrap any error with the proper status code
+func authenticate(c ctx.C, auth Authenticator, r *http.Request) (enc.Node, error) {
+	if auth == nil {
+		return nil, nil
+	}
+	uid, err := auth.Authenticate(c, r)
+	if err != nil {
+		return nil, authError(c, err)
+	}
+	return uid, nil
+}
+
+func authError(c ctx.C, err error) error {
+	if ErrorCode(err, 0) != 0 {
+		return err
+	}
+	return Error{
+		Code: 401,
+		Err:  ctx.WrapError(c, err),
+	}
+}
+
+// converts an error from api.Server.Recv() into the proper http.Error
+func recvError(c ctx.C, err error) error {
+	if errors.Is(err, api.ErrAuthRequired) {
+		return authError(c, err)
+	}
+	return Error{
+		Code: 400, // receive errors are always 4xx
+		Err:  err,
+	}
+}
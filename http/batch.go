@@ -0,0 +1,203 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ohait/forego/api/openapi"
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/ctx/log"
+	"github.com/ohait/forego/enc"
+	"github.com/ohait/forego/utils/sync"
+)
+
+// expose an endpoint which calls multiple APIs (registered with RegisterAPI) in a single request, e.g.:
+//
+//	[{"path":"/api/get","body":{"key":"a"}}, {"path":"/api/get","body":{"key":"b"}}]
+//
+// returns the status and the body of each call, in the same order
+type BatchHandler struct {
+	Path     string // default is "/api/_batch"
+	Parallel int    // how many calls at the same time, default is 1 (sequential)
+	MaxItems int    // default is 100
+}
+
+type BatchRequest struct {
+	Path    string            `json:"path"`
+	Body    enc.Node          `json:"body,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"` // added to the headers of the batch request, e.g. the Idempotency-Key
+}
+
+type BatchResponse struct {
+	Status int      `json:"status"`
+	Body   enc.Node `json:"body,omitempty"` // the response, or the problem if status is not 2xx
+}
+
+func (this BatchHandler) Register(s *Server) *openapi.PathItem {
+	if this.Path == "" {
+		this.Path = "/api/_batch"
+	}
+	if this.Parallel <= 0 {
+		this.Parallel = 1
+	}
+	if this.MaxItems <= 0 {
+		this.MaxItems = 100
+	}
+	s.handleRequest(this.Path, func(w ResponseWriter, r *Request) (any, error) {
+		c := r.Context()
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			return nil, NewErrorf(c, 405, "%s not allowed for batch", r.Method)
+		}
+		var list []BatchRequest
+		j, err := io.ReadAll(r.Body)
+		if err == nil {
+			err = enc.UnmarshalJSON(c, j, &list)
+		}
+		if err != nil {
+			return nil, NewErrorf(c, 400, "can't read batch: %w", err)
+		}
+		if len(list) > this.MaxItems {
+			return nil, NewErrorf(c, 400, "too many items in batch: %d > %d", len(list), this.MaxItems)
+		}
+		out := make([]BatchResponse, len(list))
+		items := make([]int, len(list))
+		for i := range items {
+			items[i] = i
+		}
+		err = sync.Go(c, items, this.Parallel, func(c ctx.C, i int) error {
+			out[i] = s.batchCall(c, r, list[i])
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return out, nil
+	})
+
+	pi := &openapi.PathItem{
+		Summary:     "batch",
+		Description: "call multiple APIs in a single request",
+		RequestBody: &openapi.RequestBody{
+			Content: map[string]openapi.MediaType{
+				"application/json": {
+					Schema: s.OpenAPI.MustSchemaFromType(nil, []BatchRequest{}),
+				},
+			},
+		},
+		Responses: map[string]openapi.Response{
+			"200": {
+				Content: map[string]openapi.Content{
+					"application/json": {
+						Schema: s.OpenAPI.MustSchemaFromType(nil, []BatchResponse{}),
+					},
+				},
+			},
+		},
+	}
+	s.OpenAPI.Paths[this.Path] = &openapi.Path{
+		Post: pi,
+	}
+	return pi
+}
+
+// dispatch a single call of a batch through the server, same as a normal request
+func (this *Server) batchCall(c ctx.C, r *http.Request, call BatchRequest) BatchResponse {
+	c = ctx.WithTag(c, "batch.path", call.Path)
+	fail := func(err error) BatchResponse {
+		log.Warnf(c, "batch: %v", err)
+		p := NewProblem(c, err)
+		return BatchResponse{Status: p.Status, Body: enc.MustMarshal(c, p)}
+	}
+	var body []byte
+	if call.Body != nil {
+		body = enc.JSON{}.Encode(c, call.Body)
+	}
+	req, err := http.NewRequestWithContext(c, "POST", call.Path, bytes.NewReader(body))
+	if err != nil {
+		return fail(NewErrorf(c, 400, "invalid path %q: %w", call.Path, err))
+	}
+	for k, vs := range r.Header {
+		if !batchSkipHeaders[k] {
+			req.Header[k] = vs
+		}
+	}
+	req.Header.Set(TrackingHeader, ctx.GetTracking(c)) // c is already tagged and traced
+	for k, v := range call.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = r.RemoteAddr // used by the rate limiter
+
+	_, pattern := this.mux.Handler(req)
+	if _, ok := this.apis.Load(pattern); !ok {
+		return fail(NewErrorf(c, 404, "no api for %q", call.Path))
+	}
+	w := &batchWriter{header: http.Header{}}
+	this.ServeHTTP(w, req) // same as a normal request: middlewares, rate limit, span, metrics and panic recovery
+
+	res := BatchResponse{Status: w.code}
+	if w.code == 0 {
+		res.Status = 200
+	}
+	if w.buf.Len() > 0 {
+		if strings.Contains(w.header.Get("Content-Type"), "json") {
+			res.Body, err = enc.JSON{}.Decode(c, w.buf.Bytes())
+			if err != nil {
+				return fail(err)
+			}
+		} else {
+			res.Body = enc.String(w.buf.String())
+		}
+	}
+	return res
+}
+
+// headers of the batch request not copied to each call, the Idempotency-Key can only be set per call (in BatchRequest.Headers)
+var batchSkipHeaders = map[string]bool{
+	"Content-Length":  true,
+	"Content-Type":    true,
+	"Accept":          true,
+	"Accept-Encoding": true,
+	"If-None-Match":   true,
+	IdempotencyKey:    true,
+	"Traceparent":     true,
+	"Baggage":         true,
+	// hop-by-hop
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+}
+
+// collect the response of a batch call
+type batchWriter struct {
+	header http.Header
+	code   int
+	buf    bytes.Buffer
+}
+
+var _ http.ResponseWriter = &batchWriter{}
+
+func (this *batchWriter) Header() http.Header {
+	return this.header
+}
+
+func (this *batchWriter) Write(b []byte) (int, error) {
+	if this.code == 0 {
+		this.code = 200
+	}
+	return this.buf.Write(b)
+}
+
+func (this *batchWriter) WriteHeader(code int) {
+	if this.code == 0 {
+		this.code = code
+	}
+}
@@ -0,0 +1,123 @@
+package http_test
+
+import (
+	"bytes"
+	gohttp "net/http"
+	"sync"
+	"testing"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/enc"
+	"github.com/ohait/forego/http"
+	"github.com/ohait/forego/test"
+)
+
+func TestBatch(t *testing.T) {
+	c := test.Context(t)
+
+	s := http.NewServer(c)
+	s.MustRegisterAPI(c, "/fetch", &Fetch{})
+	s.MustRegisterAPI(c, "/lookup/{key}", &Lookup{})
+	s.HandleRequest("/raw", func(r *http.Request) (any, error) {
+		return "raw", nil
+	})
+	http.BatchHandler{Parallel: 4}.Register(s)
+	test.NotNil(t, s.OpenAPI.Paths["/api/_batch"])
+
+	req, err := http.NewRequest(c, "POST", "/api/_batch", bytes.NewBufferString(`[
+		{"path":"/fetch","body":{"id":"ok"}},
+		{"path":"/fetch","body":{"id":"missing"}},
+		{"path":"/lookup/a?v=2","body":{},"headers":{"X-Request-Id":"r7"}},
+		{"path":"/raw"},
+		{"path":"/api/_batch","body":[]}
+	]`))
+	test.NoError(t, err)
+	w := &ResponseWriter{}
+	s.Mux().ServeHTTP(w, req)
+	t.Logf("res: %d %s", w.Code, w.Buf.String())
+	test.EqualsGo(t, 200, w.Code)
+
+	var out []http.BatchResponse
+	test.NoError(t, enc.UnmarshalJSON(c, w.Buf.Bytes(), &out))
+	test.EqualsGo(t, 5, len(out))
+
+	test.EqualsGo(t, 200, out[0].Status)
+	test.EqualsJSON(t, `{"out":"found"}`, out[0].Body)
+
+	test.EqualsGo(t, 404, out[1].Status)
+	test.ContainsJSON(t, out[1].Body, `item_not_found`)
+
+	test.EqualsGo(t, 200, out[2].Status)
+	test.ContainsJSON(t, out[2].Body, `a@2/r7`)
+
+	// only APIs can be batched
+	test.EqualsGo(t, 404, out[3].Status)
+	test.EqualsGo(t, 404, out[4].Status)
+}
+
+func TestBatchLimits(t *testing.T) {
+	c := test.Context(t)
+
+	s := http.NewServer(c)
+	s.MustRegisterAPI(c, "/fetch", &Fetch{})
+	http.BatchHandler{Path: "/batch", MaxItems: 1}.Register(s)
+
+	req, err := http.NewRequest(c, "POST", "/batch", bytes.NewBufferString(`[{"path":"/fetch"},{"path":"/fetch"}]`))
+	test.NoError(t, err)
+	w := &ResponseWriter{}
+	s.Mux().ServeHTTP(w, req)
+	t.Logf("res: %d %s", w.Code, w.Buf.String())
+	test.EqualsGo(t, 400, w.Code)
+}
+
+type Panicky struct {
+	Out string `api:"out" json:"out"`
+}
+
+func (this *Panicky) Do(c ctx.C) error {
+	panic("boom")
+}
+
+func TestBatchServer(t *testing.T) {
+	c := test.Context(t)
+
+	s := http.NewServer(c)
+	s.MustRegisterAPI(c, "/fetch", &Fetch{})
+	s.MustRegisterAPI(c, "/panic", &Panicky{})
+	http.BatchHandler{Parallel: 2}.Register(s)
+
+	var m sync.Mutex
+	keys := map[string]string{} // by path, as seen by the middleware
+	s.Use(func(next gohttp.Handler) gohttp.Handler {
+		return gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+			m.Lock()
+			keys[r.URL.Path] += r.Header.Get(http.IdempotencyKey) + ";"
+			m.Unlock()
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	req, err := http.NewRequest(c, "POST", "/api/_batch", bytes.NewBufferString(`[
+		{"path":"/panic","body":{}},
+		{"path":"/fetch","body":{"id":"ok"},"headers":{"Idempotency-Key":"k1"}},
+		{"path":"/fetch","body":{"id":"ok"}}
+	]`))
+	test.NoError(t, err)
+	req.Header.Set(http.IdempotencyKey, "outer")
+	w := &ResponseWriter{}
+	s.ServeHTTP(w, req)
+	t.Logf("res: %d %s", w.Code, w.Buf.String())
+	test.EqualsGo(t, 200, w.Code)
+
+	var out []http.BatchResponse
+	test.NoError(t, enc.UnmarshalJSON(c, w.Buf.Bytes(), &out))
+	test.EqualsGo(t, 3, len(out))
+	test.EqualsGo(t, 500, out[0].Status)
+	test.EqualsGo(t, 200, out[1].Status)
+	test.EqualsGo(t, 200, out[2].Status)
+
+	// each call goes through the middleware, without the key of the batch
+	test.EqualsStr(t, "outer;", keys["/api/_batch"])
+	test.EqualsStr(t, ";", keys["/panic"])
+	test.Assert(t, keys["/fetch"] == "k1;;" || keys["/fetch"] == ";k1;")
+}
@@ -0,0 +1,117 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/utils/sync"
+)
+
+// classified as ctx.ErrUnavailable, but not retried by Client
+var ErrCircuitOpen = ctx.Classify(errors.New("circuit open"), ctx.ErrUnavailable)
+
+// per host circuit breaker for `Client`, must be shared (as a pointer) between the clients calling the same hosts
+//
+// after Failures consecutive failures (transport errors or 5xx) the circuit opens, and the requests to that host
+// fail immediately with ErrCircuitOpen (as a 503). After Cooldown a single probe is let through (half open):
+// if it succeeds the circuit closes, otherwise it opens again
+type Breaker struct {
+	Failures int           // default 5
+	Cooldown time.Duration // default 10s
+
+	hosts sync.Map[string, *circuit]
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+type circuit struct {
+	m        sync.Mutex
+	state    circuitState
+	failures int
+	until    time.Time // when open, the time a probe is allowed
+}
+
+func (this *Breaker) circuit(host string) *circuit {
+	if cir, ok := this.hosts.Load(host); ok {
+		return cir
+	}
+	cir, loaded := this.hosts.LoadOrStore(host, &circuit{})
+	if !loaded {
+		Metrics.ClientCircuit.SetFunc(func() float64 {
+			cir.m.Lock()
+			defer cir.m.Unlock()
+			return float64(cir.state)
+		}, host)
+	}
+	return cir
+}
+
+func (this *Breaker) allow(c ctx.C, host string) error {
+	if this == nil {
+		return nil
+	}
+	cir := this.circuit(host)
+	cir.m.Lock()
+	defer cir.m.Unlock()
+	switch cir.state {
+	case circuitOpen:
+		if time.Now().After(cir.until) {
+			cir.state = circuitHalfOpen // this is the probe
+			return nil
+		}
+	case circuitHalfOpen: // waiting for the probe
+	default:
+		return nil
+	}
+	Metrics.ClientRejected.Observe(1, host)
+	return Error{Code: 503, Err: ctx.NewErrorf(c, "%q: %w", host, ErrCircuitOpen)}
+}
+
+func (this *Breaker) done(host string, failed bool) {
+	if this == nil {
+		return
+	}
+	cir := this.circuit(host)
+	cir.m.Lock()
+	defer cir.m.Unlock()
+	if !failed {
+		cir.state = circuitClosed
+		cir.failures = 0
+		return
+	}
+	cir.failures++
+	limit := this.Failures
+	if limit <= 0 {
+		limit = 5
+	}
+	if cir.state == circuitHalfOpen || cir.failures >= limit {
+		cd := this.Cooldown
+		if cd <= 0 {
+			cd = 10 * time.Second
+		}
+		cir.state = circuitOpen
+		cir.until = time.Now().Add(cd)
+	}
+}
+
+// the caller gave up, which says nothing about the health of the host, but if it was the probe
+// the circuit opens again, and the next request is the probe
+func (this *Breaker) cancelled(host string) {
+	if this == nil {
+		return
+	}
+	cir := this.circuit(host)
+	cir.m.Lock()
+	defer cir.m.Unlock()
+	if cir.state == circuitHalfOpen {
+		cir.state = circuitOpen
+		cir.until = time.Now()
+	}
+}
@@ -1,22 +1,38 @@
 package http
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ohait/forego/api"
 	"github.com/ohait/forego/ctx"
 	"github.com/ohait/forego/ctx/log"
+	"github.com/ohait/forego/enc"
 )
 
-// TODO circuit breaker!
 type Client struct {
 	cli     http.Client
 	BaseUrl *url.URL
+
+	Timeout time.Duration // of each attempt, the deadline of the ctx still applies
+	Retry   Retry         // only for idempotent requests, see `Retry`
+	Breaker *Breaker      // optional, shared by the copies of this client
+
+	// tags sent to the server in the W3C `baggage` header, with the tracking id and the `traceparent`
+	Baggage []string
+
+	// hosts (e.g. "billing" or "billing:8080") which get the tracking id, `traceparent` and `baggage`, besides the one of BaseUrl
+	// "*" sends them to any host, don't use it if the client may call third parties
+	Propagate []string
 	// TODO do we need a "proxy" host which will be used instead of the url host?
 }
 
@@ -29,6 +45,9 @@ func (this Client) Get(c ctx.C, url string) ([]byte, error) {
 	}
 	res, err := this.Do(req)
 	if err != nil {
+		if res != nil {
+			res.Body.Close()
+		}
 		return nil, err
 	}
 	if res.Body != nil {
@@ -50,6 +69,9 @@ func (this Client) Post(c ctx.C, url string, body []byte) ([]byte, error) {
 	req.Header.Set(`Content-Type`, `application/json`)
 	res, err := this.Do(req)
 	if err != nil {
+		if res != nil {
+			res.Body.Close()
+		}
 		return nil, err
 	}
 	if res.Body != nil {
@@ -60,15 +82,27 @@ func (this Client) Post(c ctx.C, url string, body []byte) ([]byte, error) {
 }
 
 func (this Client) Do(r *http.Request) (*http.Response, error) {
-	res, err := this.cli.Do(r)
+	c, cf := ctx.SpanWithKind(r.Context(), ctx.SpanClient, r.Method+" "+r.URL.Host+r.URL.Path)
+	r = r.WithContext(c)
+	if this.propagates(r.URL) {
+		inject(c, r, this.Baggage)
+	}
+	res, err := this.do(r)
 	if err != nil {
-		return res, ctx.NewErrorf(r.Context(), "http.Client.Do: %w", err)
+		cf(err)
+		return res, ctx.NewErrorf(c, "http.Client.Do: %w", err)
 	}
+	res.Body = cancelBody{res.Body, func() { cf(nil) }} // the span ends when the body is closed
 	switch res.StatusCode {
 	case 200, 204:
 		return res, nil
 	default:
-		return res, Error{res.StatusCode, fmt.Errorf("%s", res.Status)}
+		var err error = Error{res.StatusCode, fmt.Errorf("%s", res.Status)}
+		if retryAfter(res) {
+			err = ctx.Classify(err, ctx.ErrRetryable) // e.g. a 503 with a Retry-After
+		}
+		ctx.EndSpan(c, err)
+		return res, err
 	}
 }
 
@@ -78,52 +112,200 @@ func (this Client) API(c ctx.C, obj Doable, path string) error {
 	if err != nil {
 		return err
 	}
-	{
-		data := &api.JSON{}
-		err = h.Send(c, obj, data)
+	data := &api.JSON{}
+	err = h.Send(c, obj, data)
+	if err != nil {
+		return err
+	}
+	log.Debug(c, "client send", log.F("in", h.LogIn(obj)))
+	res, err := this.send(c, obj, path, data, "")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case 204:
+		log.Debugf(c, "204 no response")
+		return nil
+	case 200:
+		err := data.ReadFrom(c, res.Body)
 		if err != nil {
-			return err
+			return ctx.NewErrorf(c, "can't read response: %w", err)
 		}
-		j, err := json.Marshal(data.Data)
+		err = h.Recv(c, data, obj)
 		if err != nil {
-			return ctx.NewErrorf(c, "can't marshal %T: %w", obj, err)
+			return err
 		}
+		log.Debug(c, "client recv", log.F("out", h.LogOut(obj)))
+		return nil
+	default:
+		return ctx.NewErrorf(c, "can't connect: %s", res.Status)
+	}
+}
 
-		if path == "" {
-			return ctx.NewErrorf(c, "no path provided for %T", obj)
-		}
-		u, err := url.Parse(path)
-		if err != nil {
-			return ctx.NewErrorf(c, "can't parse path %q: %w", path, err)
+// call a streaming API (see `Server.RegisterStreamingAPI`), sending the `in` fields of obj, and calling f for each
+// NDJSON line received. It returns when the stream ends, c is cancelled, or f returns an error
+func (this Client) Stream(c ctx.C, obj Streamable, path string, f func(c ctx.C, n enc.Node) error) error {
+	c = ctx.WithTag(c, "api", fmt.Sprintf("stream %T", obj))
+	h, err := api.NewClient(c, obj)
+	if err != nil {
+		return err
+	}
+	data := &api.JSON{}
+	err = h.Send(c, obj, data)
+	if err != nil {
+		return err
+	}
+	c, cf := ctx.WithCancel(c)
+	defer cf(nil)
+	log.Debug(c, "client send", log.F("in", h.LogIn(obj)))
+	res, err := this.send(c, obj, path, data, "application/x-ndjson")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 204 {
+		return nil
+	}
+	// a line shaped like the error of a failed stream (see streamProblem) is held until the next line or the trailer,
+	// and only returned as an error if the trailer has the same status, so ops can still emit such objects
+	var pending enc.Node
+	r := bufio.NewReader(res.Body)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			n, err := enc.JSON{}.Decode(c, line)
+			if err != nil {
+				return ctx.NewErrorf(c, "can't decode stream line: %w", err)
+			}
+			if pending != nil {
+				err = f(c, pending)
+				if err != nil {
+					return err
+				}
+				pending = nil
+			}
+			if _, ok := streamProblem(c, n); ok {
+				pending = n
+			} else {
+				err = f(c, n)
+				if err != nil {
+					return err
+				}
+			}
 		}
-		if this.BaseUrl != nil {
-			u = this.BaseUrl.ResolveReference(u)
+		switch {
+		case err == io.EOF:
+			// if the server declared the trailer, it must be "ok" or the stream failed or was truncated
+			status := res.Trailer.Get(StreamStatus)
+			if _, declared := res.Trailer[StreamStatus]; declared && status != "ok" {
+				if p, ok := streamProblem(c, pending); ok && strconv.Itoa(p.Status) == status {
+					return ctx.NewErrorf(c, "remote: %w", p.AsError())
+				}
+				return ctx.NewErrorf(c, "stream truncated, %s: %q", StreamStatus, status)
+			}
+			if pending != nil {
+				return f(c, pending)
+			}
+			return nil
+		case err != nil:
+			if c.Err() != nil {
+				return ctx.WrapError(c, c.Err())
+			}
+			return ctx.NewErrorf(c, "stream interrupted: %w", err)
 		}
+	}
+}
 
-		req, err := NewRequest(c, "POST", u.String(), bytes.NewBuffer(j))
-		if err != nil {
-			return err
+// check if the line looks like the error sent by the server when a stream fails, e.g. `{"error":{"status":500,...}}`
+// it's only the error if the StreamStatus trailer has the same status, see Client.Stream()
+func streamProblem(c ctx.C, n enc.Node) (api.Problem, bool) {
+	var line streamError
+	m, ok := n.(enc.Map)
+	if !ok || len(m) != 1 || m["error"] == nil {
+		return line.Error, false
+	}
+	err := enc.Unmarshal(c, n, &line)
+	return line.Error, err == nil && line.Error.Status != 0
+}
+
+// build the request for an api call from data, and send it
+// error responses are returned as errors, decoding `application/problem+json` into api.Error
+func (this Client) send(c ctx.C, obj any, path string, data *api.JSON, accept string) (*http.Response, error) {
+	j, err := json.Marshal(data.Data)
+	if err != nil {
+		return nil, ctx.NewErrorf(c, "can't marshal %T: %w", obj, err)
+	}
+
+	if path == "" {
+		return nil, ctx.NewErrorf(c, "no path provided for %T", obj)
+	}
+	for k, v := range data.Path {
+		path = strings.ReplaceAll(path, "{"+k+"}", url.PathEscape(v))
+	}
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, ctx.NewErrorf(c, "can't parse path %q: %w", path, err)
+	}
+	if this.BaseUrl != nil {
+		u = this.BaseUrl.ResolveReference(u)
+	}
+	if len(data.Query) > 0 {
+		q := u.Query()
+		for k, vs := range data.Query {
+			for _, v := range vs {
+				q.Add(k, v)
+			}
 		}
+		u.RawQuery = q.Encode()
+	}
 
-		log.Debugf(c, "client[%T].Send() %s", obj, j)
-		res, err := this.Do(req)
-		if err != nil {
-			return ctx.NewErrorf(c, "can't send request: %w", err)
+	req, err := NewRequest(c, "POST", u.String(), bytes.NewBuffer(j))
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range data.Header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
 		}
-		switch res.StatusCode {
-		case 204:
-			log.Debugf(c, "204 no response")
-			return nil
-		case 200:
-			err := data.ReadFrom(c, res.Body)
-			if err != nil {
-				return ctx.NewErrorf(c, "can't read response: %w", err)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	res, err := this.Do(req)
+	if err != nil {
+		if p, ok := readProblem(c, res); ok {
+			if errors.Is(err, ctx.ErrRetryable) {
+				return nil, ctx.NewErrorf(c, "remote: %w", ctx.Classify(p.AsError(), ctx.ErrRetryable))
 			}
+			return nil, ctx.NewErrorf(c, "remote: %w", p.AsError())
+		}
+		if res != nil && res.Body != nil {
 			res.Body.Close()
-			log.Debugf(c, "client[%T].Recv() %s", obj, j)
-			return h.Recv(c, data, obj)
-		default:
-			return ctx.NewErrorf(c, "can't connect: %s", res.Status)
 		}
+		return nil, ctx.NewErrorf(c, "can't send request: %w", err)
+	}
+	return res, nil
+}
+
+// if the response is an `application/problem+json`, decode it
+func readProblem(c ctx.C, res *http.Response) (api.Problem, bool) {
+	var p api.Problem
+	if res == nil || res.Body == nil {
+		return p, false
+	}
+	defer res.Body.Close()
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "application/problem+json") {
+		return p, false
+	}
+	j, err := io.ReadAll(res.Body)
+	if err == nil {
+		err = enc.UnmarshalJSON(c, j, &p)
+	}
+	if err != nil {
+		log.Warnf(c, "can't decode problem: %v", err)
+		return p, false
 	}
+	return p, p.Status != 0
 }
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/ohait/forego/api"
 	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/ctx/log"
+	"github.com/ohait/forego/enc"
 )
 
 func NewErrorf(c ctx.C, code int, f string, args ...any) Error {
@@ -31,10 +34,100 @@ func (this Error) Unwrap() error {
 	return this.Err
 }
 
+// an Error is classified by its code, see api.StatusIs
+func (this Error) Is(target error) bool {
+	return api.StatusIs(this.Code, target)
+}
+
+// return the status of the outermost Error or api.Error in the chain, or of its class (e.g. 404 for ctx.ErrNotFound,
+// see api.ClassStatus), or def if none is found
 func ErrorCode(err error, def int) int {
-	var e Error
-	if errors.As(err, &e) {
-		return e.Code
+	if code := errorCode(err); code != 0 {
+		return code
+	}
+	if code := api.ClassStatus(err); code != 0 {
+		return code
 	}
 	return def
 }
+
+func errorCode(err error) int {
+	for err != nil {
+		switch e := err.(type) {
+		case Error:
+			return e.Code
+		case api.Error:
+			return e.Status
+		case interface{ Unwrap() []error }:
+			for _, err := range e.Unwrap() {
+				if code := errorCode(err); code != 0 {
+					return code
+				}
+			}
+			return 0
+		}
+		err = errors.Unwrap(err)
+	}
+	return 0
+}
+
+// build the RFC 7807 representation of the given error, the detail is omitted for 5xx to avoid leaking internals
+func NewProblem(c ctx.C, err error) api.Problem {
+	p := api.Problem{
+		Type:     "about:blank",
+		Status:   ErrorCode(err, 500),
+		Tracking: ctx.GetTracking(c),
+	}
+	p.Title = http.StatusText(p.Status)
+	p.Code = api.StatusCode(p.Status)
+	var invalid api.ValidationError
+	if errors.As(err, &invalid) {
+		p.Code = api.CodeInvalid
+		p.Fields = invalid.Fields
+	}
+	var ae api.Error
+	if errors.As(err, &ae) && ae.Status == p.Status {
+		if ae.Code != "" {
+			p.Code = ae.Code
+		}
+		if ae.Fields != nil {
+			p.Fields = ae.Fields
+		}
+		p.Detail = ae.Message
+	}
+	if p.Status >= 500 {
+		p.Detail = ""
+		p.Fields = nil
+	} else if p.Detail == "" && err != nil {
+		p.Detail = err.Error()
+	}
+	var cerr ctx.Error
+	if c != nil && c.Value(exposeErrorsKey{}) != nil && errors.As(err, &cerr) {
+		p.Error = &cerr
+	}
+	return p
+}
+
+type exposeErrorsKey struct{}
+
+// the problems built with the returned context (see NewProblem) include the cause of the error, with the stack and the tags,
+// so the client can log the remote cause (see ctx.RemoteError). Set by Server.ExposeErrors and ws.Handler.ExposeErrors
+// Note: this leaks internals, use it only between trusted services
+func ExposeErrors(c ctx.C) ctx.C {
+	return ctx.WithValue(c, exposeErrorsKey{}, true)
+}
+
+// write the error as `application/problem+json`
+func writeProblem(c ctx.C, w http.ResponseWriter, err error) {
+	p := NewProblem(c, err)
+	w.Header().Set("Content-Type", "application/problem+json")
+	// a 503 is only retried by clients if it has a Retry-After (see api.StatusIs)
+	if p.Status == 503 && errors.Is(err, ctx.ErrRetryable) && w.Header().Get("Retry-After") == "" {
+		w.Header().Set("Retry-After", "1")
+	}
+	w.WriteHeader(p.Status)
+	_, err = w.Write(enc.MustMarshalJSON(c, p))
+	if err != nil {
+		log.Warnf(c, "writing the error: %v", err)
+	}
+}
@@ -1,10 +1,14 @@
 package http_test
 
 import (
+	"errors"
 	"io"
+	"net/url"
 	"testing"
 
+	"github.com/ohait/forego/api"
 	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/ctx/log"
 	"github.com/ohait/forego/http"
 	"github.com/ohait/forego/test"
 )
@@ -42,4 +46,75 @@ func TestError(t *testing.T) {
 		err = ctx.NewErrorf(c, "err: %w", err)
 		test.EqualsGo(t, 403, http.ErrorCode(err, 999))
 	}
+	{
+		err := error(api.NewError(404, "item_not_found", "no item"))
+		err = ctx.NewErrorf(c, "err: %w", err)
+		test.EqualsGo(t, 404, http.ErrorCode(err, 999))
+		p := http.NewProblem(c, err)
+		test.EqualsGo(t, "item_not_found", p.Code)
+		test.EqualsGo(t, "no item", p.Detail)
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	c := test.Context(t)
+	for class, code := range map[error]int{
+		ctx.ErrInvalid:         400,
+		ctx.ErrUnauthenticated: 401,
+		ctx.ErrForbidden:       403,
+		ctx.ErrNotFound:        404,
+		ctx.ErrConflict:        409,
+		ctx.ErrUnavailable:     503,
+	} {
+		err := ctx.NewErrorf(c, "err: %w", class)
+		test.EqualsGo(t, code, http.ErrorCode(err, 999))
+		test.Assert(t, errors.Is(http.Error{Code: code}, class))
+	}
+	{ // a 503 is retryable only with a Retry-After, see TestClientRetry
+		test.EqualsGo(t, 503, http.ErrorCode(ctx.NewErrorf(c, "err: %w", ctx.ErrRetryable), 999))
+		test.Assert(t, !errors.Is(http.Error{Code: 503}, ctx.ErrRetryable))
+		test.Assert(t, errors.Is(http.Error{Code: 429}, ctx.ErrRetryable))
+		test.Assert(t, !errors.Is(http.ErrCircuitOpen, ctx.ErrRetryable))
+	}
+	{ // an explicit status wins
+		err := ctx.NewErrorf(c, "err: %w", api.NewError(410, "", "gone").Wrap(ctx.ErrNotFound))
+		test.EqualsGo(t, 410, http.ErrorCode(err, 999))
+	}
+	{
+		err := ctx.Classify(io.EOF, ctx.ErrConflict)
+		test.EqualsGo(t, 409, http.ErrorCode(err, 999))
+		test.EqualsGo(t, "Conflict", http.NewProblem(c, err).Title)
+		test.EqualsGo(t, "EOF", http.NewProblem(c, err).Detail)
+	}
+	test.Assert(t, errors.Is(http.ErrCircuitOpen, ctx.ErrUnavailable))
+}
+
+func TestExposeErrors(t *testing.T) {
+	c := test.Context(t)
+
+	s := http.NewServer(c)
+	s.ExposeErrors = true
+	s.MustRegisterAPI(c, "/fetch", &Fetch{})
+	addr, err := s.Listen(c, "127.0.0.1:0")
+	test.NoError(t, err)
+	cli := http.Client{
+		BaseUrl: &url.URL{Scheme: "http", Host: addr.String()},
+	}
+
+	err = cli.API(c, &Fetch{ID: "boom"}, "/fetch")
+	test.Error(t, err)
+	test.EqualsGo(t, 500, http.ErrorCode(err, 0))
+	var re ctx.RemoteError
+	test.Assert(t, errors.As(err, &re))
+	test.EqualsStr(t, "secret internal detail", re.Message)
+	test.Contains(t, re.Stack[0], "http/api_test.go")
+	test.NotEmpty(t, re.Tracking)
+
+	// one log line with both the local and the remote stack
+	var line log.Line
+	c = log.WithLogger(c, func(l log.Line) { line = l })
+	log.Errorf(c, "calling fetch: %v", err)
+	t.Logf("%s", line.Tags["error"])
+	test.Contains(t, line.Tags["error"].String(), `"remote":{"error":"secret internal detail","stack":["`)
+	test.Contains(t, line.Tags["error"].String(), "http/error_test.go")
 }
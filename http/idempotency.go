@@ -0,0 +1,173 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ohait/forego/api"
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/ctx/log"
+	"github.com/ohait/forego/enc"
+	"github.com/ohait/forego/utils/caches"
+)
+
+// header used by clients to make a POST safe to retry, see Server.Idempotency
+const IdempotencyKey = "Idempotency-Key"
+
+// run the request once per key, replaying the stored response for retries, headers are the ones bound to the op
+func (this *Server) idempotent(c ctx.C, w ResponseWriter, r *Request, req *api.JSON, key string, headers []string, run func() ([]byte, error)) ([]byte, error) {
+	// keys are scoped by path and identity, while the fingerprint detects a key reused for a different request
+	key = r.URL.Path + "\n" + string(enc.JSON{}.Encode(c, req.UID)) + "\n" + key
+	h := sha256.New()
+	h.Write([]byte(r.URL.RawQuery + "\n"))
+	for _, k := range slices.Sorted(maps.Keys(req.Path)) {
+		fmt.Fprintf(h, "path %q=%q\n", k, req.Path[k])
+	}
+	for _, name := range headers {
+		fmt.Fprintf(h, "header %q=%q\n", name, req.Header.Values(name))
+	}
+	h.Write(enc.JSON{}.Encode(c, req.Data))
+	fp := hex.EncodeToString(h.Sum(nil))
+
+	claim, rec, err := this.Idempotency.Begin(c, key, fp)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case rec == nil:
+		saved := false
+		defer func() {
+			if !saved { // failed, panicked, or can't be stored: the key can be retried
+				this.Idempotency.Release(c, key, claim)
+			}
+		}()
+		out, err := run()
+		if err != nil {
+			return nil, err
+		}
+		err = this.Idempotency.Save(c, key, claim, out)
+		if err != nil {
+			log.Warnf(c, "idempotency: can't save: %v", err)
+			return out, nil
+		}
+		saved = true
+		return out, nil
+	case rec.Fingerprint != fp:
+		return nil, NewErrorf(c, 422, "%s reused for a different request", IdempotencyKey)
+	case !rec.Done:
+		return nil, NewErrorf(c, 409, "a request with the same %s is in progress", IdempotencyKey)
+	default:
+		log.Infof(c, "idempotency: replaying the response")
+		w.Header().Set("Idempotent-Replayed", "true")
+		return rec.Response, nil
+	}
+}
+
+// stores the responses of the requests with an Idempotency-Key
+type IdempotencyStore interface {
+	// reserve the key if it's new and return a claim identifying the reservation and a nil record,
+	// otherwise return the existing record. Reserved keys must not expire before Save or Release
+	Begin(c ctx.C, key string, fingerprint string) (claim string, rec *IdempotencyRecord, err error)
+
+	// store the response for a key reserved by claim, the ttl starts now
+	// fails if the key has been reserved again by another claim
+	Save(c ctx.C, key string, claim string, response []byte) error
+
+	// remove a key reserved by claim (e.g. if the request failed, so it can be retried), unless reserved again by another claim
+	Release(c ctx.C, key string, claim string)
+}
+
+type IdempotencyRecord struct {
+	Fingerprint string // hash of the request, to detect a key reused for a different request
+	Done        bool   // false while the first request is still running
+	Response    []byte
+}
+
+// in memory IdempotencyStore, keeping up to maxEntries keys for the given ttl (after the response is saved)
+func NewIdempotencyMem(c ctx.C, maxEntries int, ttl time.Duration) IdempotencyStore {
+	return &idempotencyMem{
+		ttl: ttl,
+		lru: caches.NewLRU(c, maxEntries, func(c ctx.C, key string) (*memRecord, int, error) {
+			return &memRecord{}, 1, nil
+		}),
+	}
+}
+
+type idempotencyMem struct {
+	ttl time.Duration
+	lru caches.Cache[string, *memRecord]
+}
+
+type memRecord struct {
+	m       sync.Mutex
+	claim   string    // of the request which reserved the key, empty if not reserved
+	expires time.Time // set by Save
+	rec     IdempotencyRecord
+}
+
+// must be called with the lock held
+func (this *memRecord) reset() {
+	this.claim = ""
+	this.expires = time.Time{}
+	this.rec = IdempotencyRecord{}
+}
+
+var _ IdempotencyStore = &idempotencyMem{}
+
+func (this *idempotencyMem) Begin(c ctx.C, key string, fingerprint string) (string, *IdempotencyRecord, error) {
+	mr, _, err := this.lru.Get(c, key)
+	if err != nil {
+		return "", nil, err
+	}
+	mr.m.Lock()
+	defer mr.m.Unlock()
+	if mr.rec.Done && time.Now().After(mr.expires) {
+		mr.reset()
+	}
+	if mr.claim == "" {
+		mr.claim = uuid.NewString()
+		mr.rec.Fingerprint = fingerprint
+		return mr.claim, nil, nil
+	}
+	rec := mr.rec
+	return "", &rec, nil
+}
+
+func (this *idempotencyMem) Save(c ctx.C, key string, claim string, response []byte) error {
+	mr, _, err := this.lru.Get(c, key)
+	if err != nil {
+		return err
+	}
+	mr.m.Lock()
+	defer mr.m.Unlock()
+	switch mr.claim {
+	case claim:
+	case "":
+		log.Warnf(c, "idempotency: %q was evicted while running", key)
+		mr.claim = claim
+	default:
+		return ctx.NewErrorf(c, "idempotency: %q was reserved again while running", key)
+	}
+	mr.rec.Done = true
+	mr.rec.Response = response
+	mr.expires = time.Now().Add(this.ttl)
+	return nil
+}
+
+func (this *idempotencyMem) Release(c ctx.C, key string, claim string) {
+	mr, _, err := this.lru.Get(c, key)
+	if err != nil {
+		return
+	}
+	mr.m.Lock()
+	defer mr.m.Unlock()
+	if mr.claim == claim {
+		mr.reset()
+	}
+}
@@ -0,0 +1,154 @@
+package http_test
+
+import (
+	"bytes"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/http"
+	"github.com/ohait/forego/test"
+)
+
+type Pay struct {
+	Count *int64
+
+	Amount   int    `api:"in" json:"amount"`
+	WaitMS   int    `api:"in" json:"wait_ms"`
+	Currency string `api:"header,X-Currency"`
+	TX       int64  `api:"out" json:"tx"`
+	Note     string `api:"out" json:"note"`
+}
+
+func (this *Pay) Do(c ctx.C) error {
+	if this.Amount <= 0 {
+		return http.NewErrorf(c, 400, "invalid amount")
+	}
+	if this.Amount == 666 {
+		panic("cursed amount")
+	}
+	time.Sleep(time.Duration(this.WaitMS) * time.Millisecond)
+	this.TX = atomic.AddInt64(this.Count, 1)
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	c := test.Context(t)
+
+	var count int64
+	s := http.NewServer(c)
+	s.Idempotency = http.NewIdempotencyMem(c, 100, 50*time.Millisecond)
+	s.MustRegisterAPI(c, "/pay", &Pay{Count: &count})
+
+	post := func(key string, body string) *ResponseWriter {
+		req, err := http.NewRequest(c, "POST", "/pay", bytes.NewBufferString(body))
+		test.NoError(t, err)
+		if key != "" {
+			req.Header.Set(http.IdempotencyKey, key)
+		}
+		w := &ResponseWriter{}
+		s.Mux().ServeHTTP(w, req)
+		t.Logf("%s %s => %d %s", key, body, w.Code, w.Buf.String())
+		return w
+	}
+
+	w := post("k1", `{"amount":10}`)
+	test.EqualsGo(t, 200, w.Code)
+	test.EqualsStr(t, `{"note":"","tx":1}`, w.Buf.String())
+
+	w = post("k1", `{"amount":10}`)
+	test.EqualsGo(t, 200, w.Code)
+	test.EqualsStr(t, `{"note":"","tx":1}`, w.Buf.String())
+	test.EqualsGo(t, "true", w.Header().Get("Idempotent-Replayed"))
+	test.EqualsGo(t, int64(1), atomic.LoadInt64(&count))
+
+	// same key, different request
+	w = post("k1", `{"amount":11}`)
+	test.EqualsGo(t, 422, w.Code)
+
+	// no key
+	w = post("", `{"amount":10}`)
+	test.EqualsStr(t, `{"note":"","tx":2}`, w.Buf.String())
+
+	// failures are not stored
+	w = post("k2", `{"amount":0}`)
+	test.EqualsGo(t, 400, w.Code)
+	w = post("k2", `{"amount":5}`)
+	test.EqualsStr(t, `{"note":"","tx":3}`, w.Buf.String())
+
+	// concurrent retries
+	done := make(chan *ResponseWriter)
+	go func() {
+		done <- post("k3", `{"amount":1,"wait_ms":20}`)
+	}()
+	time.Sleep(5 * time.Millisecond)
+	w = post("k3", `{"amount":1,"wait_ms":20}`)
+	test.EqualsGo(t, 409, w.Code)
+	test.EqualsGo(t, 200, (<-done).Code)
+
+	// expired
+	time.Sleep(60 * time.Millisecond)
+	w = post("k1", `{"amount":10}`)
+	test.EqualsStr(t, `{"note":"","tx":5}`, w.Buf.String())
+}
+
+func TestIdempotencyRelease(t *testing.T) {
+	c := test.Context(t)
+
+	var count int64
+	s := http.NewServer(c)
+	s.Idempotency = http.NewIdempotencyMem(c, 100, time.Minute)
+	s.MustRegisterAPI(c, "/pay", &Pay{Count: &count})
+
+	post := func(key, currency string, body string) *ResponseWriter {
+		req, err := http.NewRequest(c, "POST", "/pay", bytes.NewBufferString(body))
+		test.NoError(t, err)
+		req.Header.Set(http.IdempotencyKey, key)
+		req.Header.Set("X-Currency", currency)
+		w := &ResponseWriter{}
+		s.ServeHTTP(w, req) // recovers the panics
+		t.Logf("%s %s %s => %d %s", key, currency, body, w.Code, w.Buf.String())
+		return w
+	}
+
+	// a panic releases the key
+	test.EqualsGo(t, 500, post("k1", "EUR", `{"amount":666}`).Code)
+	test.EqualsGo(t, 500, post("k1", "EUR", `{"amount":666}`).Code)
+
+	// the bound headers are part of the request
+	test.EqualsGo(t, 200, post("k2", "EUR", `{"amount":1}`).Code)
+	test.EqualsGo(t, 200, post("k2", "EUR", `{"amount":1}`).Code)
+	test.EqualsGo(t, 422, post("k2", "USD", `{"amount":1}`).Code)
+	test.EqualsGo(t, int64(1), atomic.LoadInt64(&count))
+}
+
+func TestIdempotencyReclaimed(t *testing.T) {
+	c := test.Context(t)
+	store := http.NewIdempotencyMem(c, 1, time.Minute)
+
+	a, rec, err := store.Begin(c, "k1", "fp")
+	test.NoError(t, err)
+	test.Nil(t, rec)
+
+	// evicted while a is running, and reserved again by b
+	_, _, err = store.Begin(c, "other", "fp")
+	test.NoError(t, err)
+	b, rec, err := store.Begin(c, "k1", "fp")
+	test.NoError(t, err)
+	test.Nil(t, rec)
+	test.Assert(t, a != b)
+
+	// a fails, b is still running
+	store.Release(c, "k1", a)
+	_, rec, err = store.Begin(c, "k1", "fp")
+	test.NoError(t, err)
+	test.NotNil(t, rec)
+	test.Assert(t, !rec.Done)
+	test.Error(t, store.Save(c, "k1", a, []byte("a")))
+
+	test.NoError(t, store.Save(c, "k1", b, []byte("b")))
+	_, rec, err = store.Begin(c, "k1", "fp")
+	test.NoError(t, err)
+	test.EqualsStr(t, "b", string(rec.Response))
+}
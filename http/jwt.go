@@ -0,0 +1,195 @@
+package http
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/enc"
+)
+
+// JWT is an Authenticator which verifies `Authorization: Bearer <token>` headers.
+//
+// HS256 tokens are verified using Secret, RS256 tokens using PublicKey (or Keys if the token has a `kid`).
+// The `exp` and `nbf` claims are always checked, `iss` and `aud` only if Issuer and Audience are set.
+type JWT struct {
+	Secret     []byte                    // HS256 shared secret
+	PublicKey  *rsa.PublicKey            // RS256 verification key
+	Keys       map[string]*rsa.PublicKey // RS256 verification keys by `kid`
+	PrivateKey *rsa.PrivateKey           // optional, used by Sign() for RS256
+
+	Issuer   string
+	Audience string
+	Leeway   time.Duration // allowed clock skew for `exp` and `nbf`
+
+	// the claim used as UID, default to "sub", use "*" for the whole claims object
+	Claim string
+}
+
+var _ Authenticator = JWT{}
+
+func (this JWT) Authenticate(c ctx.C, r *http.Request) (enc.Node, error) {
+	h := r.Header.Get("Authorization")
+	if h == "" {
+		return nil, nil
+	}
+	scheme, token, _ := strings.Cut(h, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return nil, NewErrorf(c, 401, "unsupported authorization scheme %q", scheme)
+	}
+	claims, err := this.Verify(c, strings.TrimSpace(token))
+	if err != nil {
+		return nil, err
+	}
+	switch this.Claim {
+	case "*":
+		return claims, nil
+	case "":
+		return claims["sub"], nil
+	default:
+		return claims[this.Claim], nil
+	}
+}
+
+// Verify checks the signature and the standard claims of the token, and returns all its claims
+func (this JWT) Verify(c ctx.C, token string) (enc.Map, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, NewErrorf(c, 401, "malformed token")
+	}
+	var head struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid,omitempty"`
+	}
+	err := jwtDecode(c, parts[0], &head)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, NewErrorf(c, 401, "malformed token signature")
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+
+	switch head.Alg {
+	case "HS256":
+		if len(this.Secret) == 0 {
+			return nil, NewErrorf(c, 401, "unsupported token algorithm %q", head.Alg)
+		}
+		mac := hmac.New(sha256.New, this.Secret)
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return nil, NewErrorf(c, 401, "invalid token signature")
+		}
+	case "RS256":
+		key := this.PublicKey
+		if head.Kid != "" && this.Keys != nil {
+			key = this.Keys[head.Kid]
+		}
+		if key == nil {
+			return nil, NewErrorf(c, 401, "no key for token algorithm %q kid %q", head.Alg, head.Kid)
+		}
+		sum := sha256.Sum256(signed)
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig) != nil {
+			return nil, NewErrorf(c, 401, "invalid token signature")
+		}
+	default:
+		return nil, NewErrorf(c, 401, "unsupported token algorithm %q", head.Alg)
+	}
+
+	var claims enc.Map
+	err = jwtDecode(c, parts[1], &claims)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if exp, ok := claims["exp"]; ok {
+		var t int64
+		if enc.Unmarshal(c, exp, &t) != nil || now.After(time.Unix(t, 0).Add(this.Leeway)) {
+			return nil, NewErrorf(c, 401, "token expired")
+		}
+	}
+	if nbf, ok := claims["nbf"]; ok {
+		var t int64
+		if enc.Unmarshal(c, nbf, &t) != nil || now.Before(time.Unix(t, 0).Add(-this.Leeway)) {
+			return nil, NewErrorf(c, 401, "token not valid yet")
+		}
+	}
+	if this.Issuer != "" {
+		var iss string
+		_ = enc.Unmarshal(c, claims["iss"], &iss)
+		if iss != this.Issuer {
+			return nil, NewErrorf(c, 403, "invalid token issuer %q", iss)
+		}
+	}
+	if this.Audience != "" && !jwtAudience(c, claims["aud"], this.Audience) {
+		return nil, NewErrorf(c, 403, "invalid token audience")
+	}
+	return claims, nil
+}
+
+// Sign creates a token for the given claims, using RS256 if PrivateKey is set, HS256 otherwise
+func (this JWT) Sign(c ctx.C, claims any) (string, error) {
+	head := enc.Map{"typ": enc.String("JWT")}
+	switch {
+	case this.PrivateKey != nil:
+		head["alg"] = enc.String("RS256")
+	case len(this.Secret) > 0:
+		head["alg"] = enc.String("HS256")
+	default:
+		return "", ctx.NewErrorf(c, "no key to sign the token")
+	}
+	payload, err := enc.MarshalJSON(c, claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(enc.JSON{}.Encode(c, head)) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+	var sig []byte
+	if this.PrivateKey != nil {
+		sum := sha256.Sum256([]byte(signed))
+		sig, err = rsa.SignPKCS1v15(rand.Reader, this.PrivateKey, crypto.SHA256, sum[:])
+		if err != nil {
+			return "", ctx.NewErrorf(c, "can't sign token: %w", err)
+		}
+	} else {
+		mac := hmac.New(sha256.New, this.Secret)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func jwtDecode(c ctx.C, part string, into any) error {
+	j, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return NewErrorf(c, 401, "malformed token")
+	}
+	err = enc.UnmarshalJSON(c, j, into)
+	if err != nil {
+		return NewErrorf(c, 401, "malformed token: %v", err)
+	}
+	return nil
+}
+
+// `aud` can be either a string or a list of strings
+func jwtAudience(c ctx.C, n enc.Node, aud string) bool {
+	switch n := n.(type) {
+	case enc.String:
+		return string(n) == aud
+	case enc.List:
+		for _, e := range n {
+			if s, ok := e.(enc.String); ok && string(s) == aud {
+				return true
+			}
+		}
+	}
+	return false
+}
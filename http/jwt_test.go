@@ -0,0 +1,93 @@
+package http_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	gohttp "net/http"
+	"testing"
+	"time"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/enc"
+	"github.com/ohait/forego/http"
+	"github.com/ohait/forego/test"
+)
+
+type Whoami struct {
+	UID UID    `api:"auth,required"`
+	Out string `api:"out" json:"out"`
+}
+
+func (this *Whoami) Do(c ctx.C) error {
+	this.Out = "hello " + string(this.UID)
+	return nil
+}
+
+func TestJWT(t *testing.T) {
+	c := test.Context(t)
+
+	hs := http.JWT{Secret: []byte("secret"), Audience: "test"}
+	tok, err := hs.Sign(c, enc.Map{
+		"sub": enc.String("alice"),
+		"aud": enc.List{enc.String("test")},
+		"exp": enc.Integer(time.Now().Add(time.Minute).Unix()),
+	})
+	test.NoError(t, err)
+	claims, err := hs.Verify(c, tok)
+	test.NoError(t, err)
+	test.EqualsJSON(t, "alice", claims["sub"])
+
+	_, err = http.JWT{Secret: []byte("other")}.Verify(c, tok)
+	test.EqualsGo(t, 401, http.ErrorCode(err, 0))
+
+	_, err = http.JWT{Secret: []byte("secret"), Audience: "prod"}.Verify(c, tok)
+	test.EqualsGo(t, 403, http.ErrorCode(err, 0))
+
+	expired, err := hs.Sign(c, enc.Map{"sub": enc.String("alice"), "exp": enc.Integer(time.Now().Add(-time.Minute).Unix())})
+	test.NoError(t, err)
+	_, err = hs.Verify(c, expired)
+	test.EqualsGo(t, 401, http.ErrorCode(err, 0))
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.NoError(t, err)
+	rs := http.JWT{PrivateKey: key, PublicKey: &key.PublicKey}
+	tok, err = rs.Sign(c, enc.Map{"sub": enc.String("bob")})
+	test.NoError(t, err)
+	claims, err = http.JWT{PublicKey: &key.PublicKey}.Verify(c, tok)
+	test.NoError(t, err)
+	test.EqualsJSON(t, "bob", claims["sub"])
+
+	_, err = hs.Verify(c, tok) // RS256 token but no public key
+	test.EqualsGo(t, 401, http.ErrorCode(err, 0))
+}
+
+func TestAuth(t *testing.T) {
+	c := test.Context(t)
+	jwt := http.JWT{Secret: []byte("secret")}
+
+	s := http.NewServer(c)
+	s.Auth = jwt
+	s.MustRegisterAPI(c, "/whoami", &Whoami{})
+
+	call := func(auth string) *ResponseWriter {
+		req, err := http.NewRequest(c, "POST", "/whoami", bytes.NewBufferString(`{}`))
+		test.NoError(t, err)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := &ResponseWriter{}
+		s.Mux().ServeHTTP(w, req)
+		t.Logf("res: %d %s", w.Code, w.Buf.String())
+		return w
+	}
+
+	test.EqualsGo(t, gohttp.StatusUnauthorized, call("").Code)
+	test.EqualsGo(t, gohttp.StatusUnauthorized, call("Bearer foo.bar.baz").Code)
+
+	tok, err := jwt.Sign(c, enc.Map{"sub": enc.String("alice")})
+	test.NoError(t, err)
+	w := call("Bearer " + tok)
+	test.EqualsGo(t, 200, w.Code)
+	test.Contains(t, w.Buf.String(), "hello alice")
+}
@@ -4,17 +4,82 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/ohait/forego/ctx/log"
 	"github.com/ohait/forego/utils/prom"
 )
 
 var Metrics = struct {
-	Request *prom.Histogram
+	Request            *prom.Histogram
+	RateLimited        *prom.Counter      // requests rejected by Server.RateLimit, by pattern
+	Connections        *prom.GaugeCounter // open connections, when Server.MaxConnections is set
+	ConnectionsWaiting *prom.Counter      // times Accept() had to wait for a connection to close
+
+	ClientRequest  *prom.Histogram // each attempt of Client, code is "error" for transport errors
+	ClientRetries  *prom.Counter   // by host
+	ClientRejected *prom.Counter   // requests not sent because the circuit was open, by host
+	ClientCircuit  *prom.Gauge     // state of the circuit by host: 0 closed, 1 open, 2 half open
+
+	LogErrors           *prom.Custom // lines logged with an error, by fingerprint (see log.TopErrors)
+	LogErrorsSuppressed *prom.Custom // lines not emitted because of log.Config.Errors, by fingerprint
 }{
 	Request: prom.Register("http_request", &prom.Histogram{
 		Buckets: prom.DefaultBuckets,
 		Labels:  []string{"method", "path", "code"},
 	}),
-	// TODO add more, like active requests gauges, open connections, websockets...
+	RateLimited: prom.RegisterCounter("http_rate_limited", &prom.Counter{
+		Desc:   "requests rejected with 429",
+		Labels: []string{"path"},
+	}),
+	Connections: prom.Register("http_connections", &prom.Gauge{
+		Desc: "open connections",
+	}).Counter(),
+	ConnectionsWaiting: prom.RegisterCounter("http_connections_waiting", &prom.Counter{
+		Desc: "connections delayed by the max connections limit",
+	}),
+	ClientRequest: prom.Register("http_client_request", &prom.Histogram{
+		Buckets: prom.DefaultBuckets,
+		Labels:  []string{"method", "host", "code"},
+	}),
+	ClientRetries: prom.RegisterCounter("http_client_retries", &prom.Counter{
+		Desc:   "requests retried",
+		Labels: []string{"host"},
+	}),
+	ClientRejected: prom.RegisterCounter("http_client_rejected", &prom.Counter{
+		Desc:   "requests rejected by the circuit breaker",
+		Labels: []string{"host"},
+	}),
+	ClientCircuit: prom.Register("http_client_circuit", &prom.Gauge{
+		Desc:   "circuit breaker state: 0 closed, 1 open, 2 half open",
+		Labels: []string{"host"},
+	}),
+	LogErrors: prom.Register("log_errors", &prom.Custom{
+		Desc: "lines logged with an error, for the most frequent fingerprints",
+		Type: "counter",
+		Func: func() map[string]any {
+			return topErrors("log_errors", func(s log.ErrorStat) int64 { return s.Count })
+		},
+	}),
+	LogErrorsSuppressed: prom.Register("log_errors_suppressed", &prom.Custom{
+		Desc: "lines not emitted because of log.Config.Errors, for the most frequent fingerprints",
+		Type: "counter",
+		Func: func() map[string]any {
+			return topErrors("log_errors_suppressed", func(s log.ErrorStat) int64 { return s.Suppressed })
+		},
+	}),
+	// TODO add more, like active requests gauges, websockets...
+}
+
+// only the most frequent fingerprints are exported, to limit the cardinality
+const promTopErrors = 20
+
+func topErrors(name string, val func(log.ErrorStat) int64) map[string]any {
+	out := map[string]any{}
+	for _, s := range log.TopErrors(promTopErrors) {
+		if v := val(s); v > 0 {
+			out[fmt.Sprintf("%s{fingerprint=%q}", name, s.Fingerprint)] = v
+		}
+	}
+	return out
 }
 
 type metric struct {
@@ -1,9 +1,11 @@
 package http
 
 import (
+	"encoding/json"
 	"net/http"
 	nprof "net/http/pprof"
 	"runtime/pprof"
+	"strconv"
 
 	"github.com/ohait/forego/ctx"
 	"github.com/ohait/forego/ctx/log"
@@ -17,6 +19,107 @@ func (this *Server) SetupPrometheus(c ctx.C, path string) {
 	this.mux.Handle(path, prom.Handler())
 }
 
+// expose the log configuration (see log.Config), default path is /admin/log:
+// GET returns it, PUT replaces it, and POST changes only the given fields (and keys, null removes them)
+//
+//	curl -X POST -d '{"packages":{"forego/http/":"info"}}' localhost:8080/admin/log
+//
+// Note: like SetupMonitoring, this should not be reachable from the outside
+func (this *Server) SetupLogAdmin(c ctx.C, path string) {
+	if path == "" {
+		path = "/admin/log"
+	}
+	this.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		c := r.Context()
+		cfg := log.GetConfig()
+		switch r.Method {
+		case "GET", "HEAD":
+		case "PUT":
+			cfg = log.Config{}
+			err := json.NewDecoder(r.Body).Decode(&cfg)
+			if err != nil {
+				writeProblem(c, w, NewErrorf(c, 400, "can't parse config: %v", err))
+				return
+			}
+			log.SetConfig(cfg)
+			log.Infof(c, "log config replaced: %+v", cfg)
+		case "POST":
+			var in struct {
+				Level    *log.Level            `json:"level"`
+				Packages map[string]*log.Level `json:"packages"`
+				Sampling map[string]*int       `json:"sampling"`
+				Errors   *int                  `json:"errors"`
+			}
+			err := json.NewDecoder(r.Body).Decode(&in)
+			if err != nil {
+				writeProblem(c, w, NewErrorf(c, 400, "can't parse config: %v", err))
+				return
+			}
+			if in.Level != nil {
+				cfg.Level = *in.Level
+			}
+			if in.Errors != nil {
+				cfg.Errors = *in.Errors
+			}
+			for k, v := range in.Packages {
+				if v == nil {
+					delete(cfg.Packages, k)
+				} else {
+					cfg.Packages[k] = *v
+				}
+			}
+			for k, v := range in.Sampling {
+				if v == nil {
+					delete(cfg.Sampling, k)
+				} else {
+					cfg.Sampling[k] = *v
+				}
+			}
+			log.SetConfig(cfg)
+			log.Infof(c, "log config changed: %+v", cfg)
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			w.WriteHeader(405)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(cfg)
+	})
+}
+
+// expose the most frequent errors logged (see log.TopErrors), default path is /admin/errors:
+// GET returns the top `n` (default 50), DELETE resets the counters
+//
+// Note: like SetupMonitoring, this should not be reachable from the outside
+func (this *Server) SetupErrors(c ctx.C, path string) {
+	if path == "" {
+		path = "/admin/errors"
+	}
+	this.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		c := r.Context()
+		switch r.Method {
+		case "GET", "HEAD":
+			n := 50
+			if s := r.URL.Query().Get("n"); s != "" {
+				var err error
+				n, err = strconv.Atoi(s)
+				if err != nil {
+					writeProblem(c, w, NewErrorf(c, 400, "invalid n: %q", s))
+					return
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(log.TopErrors(n))
+		case "DELETE":
+			log.ResetErrors()
+			w.WriteHeader(204)
+		default:
+			w.Header().Set("Allow", "GET, DELETE")
+			w.WriteHeader(405)
+		}
+	})
+}
+
 // register /pprof/ and /goroutines under the given prefix
 func (this *Server) SetupMonitoring(c ctx.C, prefix string) {
 	this.mux.HandleFunc(prefix+"/pprof/", nprof.Index)
@@ -0,0 +1,84 @@
+package http_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/ohait/forego/ctx/log"
+	"github.com/ohait/forego/http"
+	"github.com/ohait/forego/test"
+)
+
+func TestLogAdmin(t *testing.T) {
+	c := test.Context(t)
+	old := log.GetConfig()
+	t.Cleanup(func() { log.SetConfig(old) })
+
+	s := http.NewServer(c)
+	s.SetupLogAdmin(c, "")
+
+	call := func(method, body string) *ResponseWriter {
+		req, err := http.NewRequest(c, method, "/admin/log", bytes.NewBufferString(body))
+		test.NoError(t, err)
+		w := &ResponseWriter{}
+		s.ServeHTTP(w, req)
+		t.Logf("%s %s => %d %s", method, body, w.Code, w.Buf.String())
+		return w
+	}
+
+	w := call("POST", `{"level":"warn","packages":{"forego/http/":"info"}}`)
+	test.EqualsGo(t, 200, w.Code)
+	test.EqualsGo(t, log.LevelWarn, log.GetConfig().Level)
+	test.EqualsGo(t, log.LevelInfo, log.GetConfig().Packages["forego/http/"])
+
+	w = call("POST", `{"packages":{"forego/http/":null}}`)
+	test.EqualsGo(t, 200, w.Code)
+	test.EqualsGo(t, 0, len(log.GetConfig().Packages))
+	test.EqualsGo(t, log.LevelWarn, log.GetConfig().Level)
+
+	w = call("GET", ``)
+	test.ContainsJSON(t, w.Buf.String(), `"level":"warn"`)
+
+	w = call("PUT", `{"level":"nope"}`)
+	test.EqualsGo(t, 400, w.Code)
+}
+
+func TestErrorsAdmin(t *testing.T) {
+	c := test.Context(t)
+	log.ResetErrors()
+	t.Cleanup(log.ResetErrors)
+
+	s := http.NewServer(c)
+	s.SetupErrors(c, "")
+	s.SetupPrometheus(c, "")
+
+	call := func(method, path string) *ResponseWriter {
+		req, err := http.NewRequest(c, method, path, nil)
+		test.NoError(t, err)
+		w := &ResponseWriter{}
+		s.ServeHTTP(w, req)
+		t.Logf("%s %s => %d %s", method, path, w.Code, w.Buf.String())
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		log.Warnf(c, "oops: %v", io.ErrUnexpectedEOF)
+	}
+	fp := log.Fingerprint(io.ErrUnexpectedEOF, log.TopErrors(1)[0].Src)
+
+	w := call("GET", "/admin/errors?n=5")
+	test.EqualsGo(t, 200, w.Code)
+	test.ContainsJSON(t, w.Buf.String(), `"fingerprint":"`+fp+`"`)
+	test.ContainsJSON(t, w.Buf.String(), `"count":3`)
+
+	w = call("GET", "/metrics")
+	test.Contains(t, w.Buf.String(), `log_errors{fingerprint="`+fp+`"} 3`)
+
+	w = call("GET", "/admin/errors?n=x")
+	test.EqualsGo(t, 400, w.Code)
+
+	w = call("DELETE", "/admin/errors")
+	test.EqualsGo(t, 204, w.Code)
+	test.EqualsGo(t, 0, len(log.TopErrors(0)))
+}
@@ -44,7 +44,7 @@ func HandleRequest[Req any](
 			http.Error(w, "can't decode request body: "+err.Error(), 400)
 			return
 		}
-		log.Debugf(c, "decoded request: %+v", req)
+		log.Debug(c, "decoded request", log.F("request", req))
 
 		// call the function
 		err = f(c, req, w)
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ohait/forego/ctx"
+)
+
+// header used to propagate the tracking id between services, see `ctx.WithTracking()`
+const TrackingHeader = "X-Tracking-Id"
+
+// true if the tracking headers can be sent to u, see Client.Propagate
+func (this Client) propagates(u *url.URL) bool {
+	if this.BaseUrl != nil && this.BaseUrl.Host == u.Host {
+		return true
+	}
+	for _, h := range this.Propagate {
+		if h == "*" || h == u.Host || h == u.Hostname() {
+			return true
+		}
+	}
+	return false
+}
+
+// set the tracking headers, and the baggage with the given tags (if present in c)
+func inject(c ctx.C, r *http.Request, tags []string) {
+	if r.Header.Get(TrackingHeader) == "" && ctx.GetTracking(c) != "" {
+		r.Header.Set(TrackingHeader, ctx.GetTracking(c))
+	}
+	if sc := ctx.GetSpan(c); sc.TraceID != "" && r.Header.Get("traceparent") == "" {
+		if sc.SpanID == "" { // no exporter, but the callee might have one
+			sc.SpanID = ctx.NewSpanID()
+		}
+		r.Header.Set("traceparent", "00-"+sc.TraceID+"-"+sc.SpanID+"-01")
+	}
+	if len(tags) > 0 && r.Header.Get("baggage") == "" {
+		vals := map[string]string{}
+		_ = ctx.RangeTag(c, func(k string, j ctx.JSON) error {
+			if slices.Contains(tags, k) {
+				vals[k] = string(j) // the last one wins
+			}
+			return nil
+		})
+		var list []string
+		for _, k := range tags {
+			if v, ok := vals[k]; ok {
+				list = append(list, url.PathEscape(k)+"="+url.PathEscape(v))
+			}
+		}
+		if len(list) > 0 {
+			r.Header.Set("baggage", strings.Join(list, ","))
+		}
+	}
+}
+
+// use the tracking id of the caller, or create a new one, and tag c with the allowed tags of the baggage
+func adopt(c ctx.C, r *http.Request, tags []string) ctx.C {
+	id := r.Header.Get(TrackingHeader)
+	if m := traceparentRE.FindStringSubmatch(r.Header.Get("traceparent")); m != nil {
+		t := m[1]
+		if id == "" {
+			id = t[0:8] + "-" + t[8:12] + "-" + t[12:16] + "-" + t[16:20] + "-" + t[20:]
+		}
+		c = ctx.WithRemoteSpan(c, ctx.SpanContext{TraceID: t, SpanID: m[2]})
+	}
+	c = ctx.WithTracking(c, id)
+	if len(tags) == 0 {
+		return c
+	}
+	for _, kv := range strings.Split(r.Header.Get("baggage"), ",") {
+		kv, _, _ = strings.Cut(kv, ";") // ignore the properties
+		k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok {
+			continue
+		}
+		k, err1 := url.PathUnescape(k)
+		v, err2 := url.PathUnescape(v)
+		if err1 != nil || err2 != nil || !slices.Contains(tags, k) {
+			continue
+		}
+		if json.Valid([]byte(v)) {
+			c = ctx.WithTag(c, k, json.RawMessage(v))
+		} else {
+			c = ctx.WithTag(c, k, v)
+		}
+	}
+	return c
+}
+
+var traceparentRE = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-([0-9a-f]{16})-[0-9a-f]{2}$`)
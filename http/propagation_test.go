@@ -0,0 +1,117 @@
+package http_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	gohttp "net/http"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/ctx/trace"
+	"github.com/ohait/forego/http"
+	"github.com/ohait/forego/test"
+)
+
+func TestPropagation(t *testing.T) {
+	c := test.Context(t)
+
+	var tracking string
+	var tags map[string]string
+	s := http.NewServer(c)
+	s.Baggage = []string{"tenant"}
+	s.HandleFunc("/hop", func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		c := r.Context()
+		tracking = ctx.GetTracking(c)
+		tags = map[string]string{}
+		_ = ctx.RangeTag(c, func(k string, j ctx.JSON) error {
+			tags[k] = string(j)
+			return nil
+		})
+		w.WriteHeader(204)
+	})
+	addr, err := s.Listen(c, "127.0.0.1:0")
+	test.NoError(t, err)
+	url := "http://" + addr.String() + "/hop"
+
+	// no tracking, the server creates one
+	_, err = http.DefaultClient.Get(c, url)
+	test.NoError(t, err)
+	test.Assert(t, strings.HasSuffix(tracking, ".1")) // the span of the server
+	test.EqualsGo(t, 1, strings.Count(tracking, "."))
+
+	cli := http.Client{Baggage: []string{"tenant", "user"}}
+	c = ctx.WithTracking(c, "0af76519-16cd-43dd-8448-eb211c80319c")
+	c = ctx.WithTag(c, "tenant", "acme")
+	c = ctx.WithTag(c, "user", 42)
+	c = ctx.WithTag(c, "secret", "nope")
+
+	// not an allowed host, nothing is sent
+	_, err = cli.Get(c, url)
+	test.NoError(t, err)
+	test.Assert(t, !strings.HasPrefix(tracking, "0af76519"))
+	test.EqualsGo(t, "", tags["tenant"])
+
+	cli.Propagate = []string{"127.0.0.1"}
+	_, err = cli.Get(c, url)
+	test.NoError(t, err)
+	test.EqualsGo(t, "0af76519-16cd-43dd-8448-eb211c80319c.1.1", tracking) // client span, then server span
+	test.EqualsGo(t, `"acme"`, tags["tenant"])
+	test.EqualsGo(t, "", tags["user"]) // not allowed by the server
+	test.EqualsGo(t, "", tags["secret"])
+
+	// a W3C only caller
+	req, err := http.NewRequest(c, "GET", url, nil)
+	test.NoError(t, err)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	res, err := gohttp.DefaultClient.Do(req)
+	test.NoError(t, err)
+	res.Body.Close()
+	test.EqualsGo(t, "4bf92f35-77b3-4da6-a3ce-929d0e0e4736.1", tracking)
+	test.EqualsGo(t, "4bf92f35-77b3-4da6-a3ce-929d0e0e4736", res.Header.Get(http.TrackingHeader))
+
+	// the tracking id of the caller is returned as is
+	req, err = http.NewRequest(c, "GET", url, nil)
+	test.NoError(t, err)
+	req.Header.Set(http.TrackingHeader, "abcd")
+	res, err = gohttp.DefaultClient.Do(req)
+	test.NoError(t, err)
+	res.Body.Close()
+	test.EqualsGo(t, "abcd.1", tracking) // the span of the server
+	test.EqualsGo(t, "abcd", res.Header.Get(http.TrackingHeader))
+}
+
+func TestSpans(t *testing.T) {
+	mem := &trace.Memory{}
+	c := ctx.WithSpanExporter(test.Context(t), mem)
+
+	var count int64
+	s := http.NewServer(c)
+	s.MustRegisterAPI(c, "/pay", &Pay{Count: &count})
+	addr, err := s.Listen(c, "127.0.0.1:0")
+	test.NoError(t, err)
+
+	cli := http.Client{BaseUrl: &url.URL{Scheme: "http", Host: addr.String()}}
+	err = cli.API(c, &Pay{Amount: 1}, "/pay")
+	test.NoError(t, err)
+
+	client := mem.Find("POST " + addr.String() + "/pay")
+	server := mem.Find("POST /pay")
+	op := mem.Find("api http_test.Pay")
+	test.NotNil(t, client)
+	test.NotNil(t, server)
+	test.NotNil(t, op)
+	test.EqualsGo(t, ctx.SpanClient, client.Kind)
+	test.EqualsGo(t, ctx.SpanServer, server.Kind)
+	test.EqualsGo(t, ctx.SpanInternal, op.Kind)
+	test.EqualsGo(t, client.TraceID, server.TraceID)
+	test.EqualsGo(t, client.SpanID, server.ParentID)
+	test.EqualsGo(t, server.SpanID, op.ParentID)
+
+	mem.Reset()
+	err = cli.API(c, &Pay{Amount: -1}, "/pay")
+	test.Assert(t, err != nil)
+	op = mem.Find("api http_test.Pay")
+	test.NotNil(t, op)
+	test.Contains(t, op.Err.Error(), "invalid amount")
+}
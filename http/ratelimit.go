@@ -0,0 +1,188 @@
+package http
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ohait/forego/api"
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/enc"
+)
+
+// token bucket: up to Burst requests at once, refilled at Rate per second
+type Limit struct {
+	Rate  float64
+	Burst int // default is Rate rounded up
+}
+
+// identify the client of a request, for rate limiting
+type ClientKey func(r *http.Request) string
+
+// limit the requests by route pattern and client, set it as `Server.RateLimit`
+// requests over the limit get a 429 with a Retry-After header
+type RateLimiter struct {
+	Default Limit            // for all the routes, no limit if zero
+	Routes  map[string]Limit // by pattern, overrides the Default
+	Client  ClientKey        // default is ByRemoteAddr
+
+	m         sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+type bucketKey struct {
+	pattern string
+	client  string
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// the IP address of the client (ignoring proxies)
+func ByRemoteAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// the value of a header, e.g. an API key, falls back to the remote address if missing
+func ByHeader(name string) ClientKey {
+	return func(r *http.Request) string {
+		if v := r.Header.Get(name); v != "" {
+			return name + ":" + v
+		}
+		return ByRemoteAddr(r)
+	}
+}
+
+// the UID returned by the authenticator, falls back to the remote address if no (valid) credentials
+func ByAuth(auth Authenticator) ClientKey {
+	return func(r *http.Request) string {
+		uid, err := auth.Authenticate(r.Context(), r)
+		if err != nil || uid == nil {
+			return ByRemoteAddr(r)
+		}
+		return "uid:" + string(enc.JSON{}.Encode(r.Context(), uid))
+	}
+}
+
+func (this Limit) burst() float64 {
+	if this.Burst > 0 {
+		return float64(this.Burst)
+	}
+	return math.Max(1, math.Ceil(this.Rate))
+}
+
+// take a token, or return how long to wait for the next one
+func (this *RateLimiter) allow(r *http.Request, pattern string) (time.Duration, bool) {
+	l, ok := this.Routes[pattern]
+	if !ok {
+		l = this.Default
+	}
+	if l.Rate <= 0 {
+		return 0, true
+	}
+	client := this.Client
+	if client == nil {
+		client = ByRemoteAddr
+	}
+	k := bucketKey{pattern, client(r)}
+	now := time.Now()
+
+	this.m.Lock()
+	defer this.m.Unlock()
+	if this.buckets == nil {
+		this.buckets = map[bucketKey]*bucket{}
+	}
+	this.sweep(now)
+	b := this.buckets[k]
+	if b == nil {
+		b = &bucket{tokens: l.burst(), last: now}
+		this.buckets[k] = b
+	}
+	b.tokens = math.Min(l.burst(), b.tokens+now.Sub(b.last).Seconds()*l.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - b.tokens) / l.Rate * float64(time.Second)), false
+}
+
+// once a minute, remove the buckets which have been refilled, must be called with the lock held
+func (this *RateLimiter) sweep(now time.Time) {
+	if now.Sub(this.lastSweep) < time.Minute {
+		return
+	}
+	this.lastSweep = now
+	for k, b := range this.buckets {
+		l, ok := this.Routes[k.pattern]
+		if !ok {
+			l = this.Default
+		}
+		if b.tokens+now.Sub(b.last).Seconds()*l.Rate >= l.burst() {
+			delete(this.buckets, k)
+		}
+	}
+}
+
+// called before routing, returns false if the request has been rejected
+func (this *Server) rateLimit(c ctx.C, w http.ResponseWriter, r *http.Request, pattern string) bool {
+	if this.RateLimit == nil {
+		return true
+	}
+	wait, ok := this.RateLimit.allow(r, pattern)
+	if ok {
+		return true
+	}
+	r.Pattern = pattern // not routed, but still reported in the metrics
+	Metrics.RateLimited.Observe(1, pattern)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	writeProblem(c, w, api.NewError(429, "rate_limited", "too many requests, retry in %v", wait.Round(time.Millisecond)))
+	return false
+}
+
+// limit the number of open connections, Accept() blocks until one is closed
+type limitListener struct {
+	net.Listener
+	sem chan struct{}
+}
+
+func (this *limitListener) Accept() (net.Conn, error) {
+	select {
+	case this.sem <- struct{}{}:
+	default:
+		Metrics.ConnectionsWaiting.Observe(1)
+		this.sem <- struct{}{}
+	}
+	conn, err := this.Listener.Accept()
+	if err != nil {
+		<-this.sem
+		return nil, err
+	}
+	Metrics.Connections.Inc(1)
+	return &limitConn{Conn: conn, release: func() {
+		Metrics.Connections.Dec(1)
+		<-this.sem
+	}}, nil
+}
+
+type limitConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (this *limitConn) Close() error {
+	err := this.Conn.Close()
+	this.once.Do(this.release)
+	return err
+}
@@ -0,0 +1,127 @@
+package http_test
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ohait/forego/enc"
+	"github.com/ohait/forego/http"
+	"github.com/ohait/forego/test"
+)
+
+func TestRateLimit(t *testing.T) {
+	c := test.Context(t)
+
+	var count int64
+	s := http.NewServer(c)
+	s.RateLimit = &http.RateLimiter{
+		Default: http.Limit{Rate: 1, Burst: 2},
+		Routes: map[string]http.Limit{
+			"/free": {},
+		},
+		Client: http.ByHeader("X-Api-Key"),
+	}
+	s.MustRegisterAPI(c, "/pay", &Pay{Count: &count})
+	s.MustRegisterAPI(c, "/free", &Pay{Count: &count})
+
+	post := func(path, key string) *ResponseWriter {
+		req, err := http.NewRequest(c, "POST", path, bytes.NewBufferString(`{"amount":1}`))
+		test.NoError(t, err)
+		req.RemoteAddr = "10.0.0.1:1234"
+		if key != "" {
+			req.Header.Set("X-Api-Key", key)
+		}
+		w := &ResponseWriter{}
+		s.ServeHTTP(w, req)
+		t.Logf("%s %s => %d %s", path, key, w.Code, w.Buf.String())
+		return w
+	}
+
+	test.EqualsGo(t, 200, post("/pay", "a").Code)
+	test.EqualsGo(t, 200, post("/pay", "a").Code)
+	w := post("/pay", "a")
+	test.EqualsGo(t, 429, w.Code)
+	test.EqualsGo(t, "1", w.Header().Get("Retry-After"))
+	test.Contains(t, w.Buf.String(), `"code":"rate_limited"`)
+
+	// other clients and unlimited routes are not affected
+	test.EqualsGo(t, 200, post("/pay", "b").Code)
+	test.EqualsGo(t, 200, post("/pay", "").Code)
+	for i := 0; i < 5; i++ {
+		test.EqualsGo(t, 200, post("/free", "a").Code)
+	}
+
+	time.Sleep(time.Second)
+	test.EqualsGo(t, 200, post("/pay", "a").Code)
+}
+
+func TestRateLimitBatch(t *testing.T) {
+	c := test.Context(t)
+
+	var count int64
+	s := http.NewServer(c)
+	s.RateLimit = &http.RateLimiter{
+		Default: http.Limit{Rate: 0.01, Burst: 2},
+	}
+	s.MustRegisterAPI(c, "/pay", &Pay{Count: &count})
+	http.BatchHandler{}.Register(s)
+
+	req, err := http.NewRequest(c, "POST", "/api/_batch", bytes.NewBufferString(`[
+		{"path":"/pay","body":{"amount":1}},
+		{"path":"/pay","body":{"amount":1}},
+		{"path":"/pay","body":{"amount":1}}
+	]`))
+	test.NoError(t, err)
+	req.RemoteAddr = "10.0.0.1:1234"
+	w := &ResponseWriter{}
+	s.ServeHTTP(w, req)
+	t.Logf("batch => %d %s", w.Code, w.Buf.String())
+	test.EqualsGo(t, 200, w.Code)
+
+	// each call costs a token
+	var out []http.BatchResponse
+	test.NoError(t, enc.UnmarshalJSON(c, w.Buf.Bytes(), &out))
+	test.EqualsGo(t, 200, out[0].Status)
+	test.EqualsGo(t, 200, out[1].Status)
+	test.EqualsGo(t, 429, out[2].Status)
+	test.EqualsGo(t, int64(2), count)
+}
+
+func TestMaxConnections(t *testing.T) {
+	c := test.Context(t)
+	s := http.NewServer(c)
+	s.MaxConnections = 1
+	addr, err := s.Listen(c, "127.0.0.1:0")
+	test.NoError(t, err)
+
+	// hold the only connection
+	conn, err := net.Dial("tcp", addr.String())
+	test.NoError(t, err)
+	_, err = conn.Write([]byte("GET /live HTTP/1.1\r\nHost: x\r\n\r\n"))
+	test.NoError(t, err)
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	test.NoError(t, err)
+	test.Contains(t, string(buf[:n]), "204")
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := http.DefaultClient.Get(c, "http://"+addr.String()+"/live")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("expected to wait, got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	_ = conn.Close()
+	select {
+	case err := <-done:
+		test.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatalf("still waiting after the connection was closed")
+	}
+}
@@ -0,0 +1,152 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ohait/forego/api"
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/ctx/log"
+)
+
+// retry policy for `Client`, only idempotent requests are retried:
+// GET, HEAD, OPTIONS, TRACE, PUT, DELETE, and any request with an `Idempotency-Key` header
+type Retry struct {
+	Max      int           // attempts after the first one, no retries if zero
+	Base     time.Duration // delay before the first retry, doubled at each attempt, default 100ms
+	MaxDelay time.Duration // cap of the delay, default 10s
+}
+
+// return how long to wait before attempt n (starting from 1), using full jitter
+func (this Retry) backoff(n int) time.Duration {
+	base := this.Base
+	if base <= 0 {
+		base = 100 * time.Millisecond
+	}
+	limit := this.MaxDelay
+	if limit <= 0 {
+		limit = 10 * time.Second
+	}
+	d := base
+	for i := 1; i < n && d < limit; i++ {
+		d *= 2
+	}
+	return rand.N(min(d, limit)) + 1
+}
+
+// true if the response is classified as ctx.ErrRetryable
+func retryAfter(res *http.Response) bool {
+	if res.StatusCode == 503 {
+		return res.Header.Get("Retry-After") != ""
+	}
+	return api.StatusIs(res.StatusCode, ctx.ErrRetryable)
+}
+
+func idempotent(r *http.Request) bool {
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+	default:
+		if r.Header.Get(IdempotencyKey) == "" {
+			return false
+		}
+	}
+	return r.Body == nil || r.Body == http.NoBody || r.GetBody != nil
+}
+
+// transport errors (unless classified otherwise, e.g. ErrCircuitOpen), the statuses classified as ctx.ErrRetryable
+// (429, 502 and 504) and a 503 with a Retry-After are worth a retry, returns the Retry-After if any
+func retryable(res *http.Response, err error) (time.Duration, bool) {
+	if err != nil {
+		if errorCode(err) != 0 || api.ClassStatus(err) != 0 {
+			return 0, errors.Is(err, ctx.ErrRetryable)
+		}
+		return 0, true
+	}
+	if retryAfter(res) {
+		secs, _ := strconv.Atoi(res.Header.Get("Retry-After"))
+		return time.Duration(secs) * time.Second, true
+	}
+	return 0, false
+}
+
+// send the request, retrying according to this.Retry, each attempt limited by this.Timeout and checked by this.Breaker
+func (this Client) do(r *http.Request) (*http.Response, error) {
+	c := r.Context()
+	host := r.URL.Host
+	retries := 0
+	if idempotent(r) {
+		retries = this.Retry.Max
+	}
+	for n := 0; ; n++ {
+		if n > 0 && r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, ctx.NewErrorf(c, "can't rewind the body: %w", err)
+			}
+			r.Body = body
+		}
+		res, err := this.attempt(c, host, r)
+		after, ok := retryable(res, err)
+		if !ok || n >= retries || c.Err() != nil {
+			return res, err
+		}
+		wait := max(this.Retry.backoff(n+1), after)
+		if dl, ok := c.Deadline(); ok && time.Now().Add(wait).After(dl) {
+			log.Debugf(c, "not retrying %s %s, the deadline is in %v", r.Method, r.URL, time.Until(dl))
+			return res, err
+		}
+		if res != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+			res.Body.Close()
+		}
+		log.Debugf(c, "retry %d/%d of %s %s in %v: %v", n+1, retries, r.Method, r.URL, wait, err)
+		Metrics.ClientRetries.Observe(1, host)
+		select {
+		case <-c.Done():
+			return nil, ctx.WrapError(c, c.Err())
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (this Client) attempt(c ctx.C, host string, r *http.Request) (*http.Response, error) {
+	if err := this.Breaker.allow(c, host); err != nil {
+		return nil, err
+	}
+	cf := func() {}
+	if this.Timeout > 0 {
+		c, cf = ctx.WithTimeout(c, this.Timeout)
+	}
+	t0 := time.Now()
+	res, err := this.cli.Do(r.WithContext(c))
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(res.StatusCode)
+		res.Body = cancelBody{res.Body, cf} // the body can be read until closed
+	} else {
+		cf()
+	}
+	Metrics.ClientRequest.Observe(time.Since(t0).Seconds(), r.Method, host, code)
+	// a cancelled caller says nothing about the health of the host
+	if r.Context().Err() == nil {
+		this.Breaker.done(host, err != nil || res.StatusCode >= 500)
+	} else {
+		this.Breaker.cancelled(host)
+	}
+	return res, err
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cf func()
+}
+
+func (this cancelBody) Close() error {
+	err := this.ReadCloser.Close()
+	this.cf()
+	return err
+}
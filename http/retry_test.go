@@ -0,0 +1,155 @@
+package http_test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gohttp "net/http"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/http"
+	"github.com/ohait/forego/test"
+)
+
+func TestClientRetry(t *testing.T) {
+	c := test.Context(t)
+
+	var calls, fail int64
+	srv := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		atomic.AddInt64(&calls, 1)
+		if atomic.AddInt64(&fail, -1) >= 0 {
+			if after := r.URL.Query().Get("after"); after != "" {
+				w.Header().Set("Retry-After", after)
+			}
+			w.WriteHeader(503)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	cli := http.Client{Retry: http.Retry{Max: 3, Base: time.Millisecond}}
+
+	atomic.StoreInt64(&fail, 2)
+	out, err := cli.Get(c, srv.URL+"?after=0")
+	test.NoError(t, err)
+	test.EqualsStr(t, "ok", string(out))
+	test.EqualsGo(t, int64(3), atomic.LoadInt64(&calls))
+
+	// too many failures
+	atomic.StoreInt64(&calls, 0)
+	atomic.StoreInt64(&fail, 10)
+	_, err = cli.Get(c, srv.URL+"?after=0")
+	test.EqualsGo(t, 503, http.ErrorCode(err, 0))
+	test.Assert(t, errors.Is(err, ctx.ErrRetryable))
+	test.EqualsGo(t, int64(4), atomic.LoadInt64(&calls))
+
+	// a 503 without Retry-After is only unavailable
+	atomic.StoreInt64(&calls, 0)
+	atomic.StoreInt64(&fail, 1)
+	_, err = cli.Get(c, srv.URL)
+	test.EqualsGo(t, 503, http.ErrorCode(err, 0))
+	test.Assert(t, errors.Is(err, ctx.ErrUnavailable))
+	test.Assert(t, !errors.Is(err, ctx.ErrRetryable))
+	test.EqualsGo(t, int64(1), atomic.LoadInt64(&calls))
+
+	// POST is not idempotent
+	atomic.StoreInt64(&calls, 0)
+	atomic.StoreInt64(&fail, 1)
+	_, err = cli.Post(c, srv.URL+"?after=0", []byte(`{}`))
+	test.EqualsGo(t, 503, http.ErrorCode(err, 0))
+	test.EqualsGo(t, int64(1), atomic.LoadInt64(&calls))
+}
+
+func TestClientTimeout(t *testing.T) {
+	c := test.Context(t)
+
+	srv := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	cli := http.Client{Timeout: 20 * time.Millisecond}
+	t0 := time.Now()
+	_, err := cli.Get(c, srv.URL)
+	test.Assert(t, err != nil)
+	test.Assert(t, time.Since(t0) < 500*time.Millisecond)
+}
+
+func TestClientBreaker(t *testing.T) {
+	c := test.Context(t)
+
+	var calls, ok int64
+	srv := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		atomic.AddInt64(&calls, 1)
+		if atomic.LoadInt64(&ok) == 0 {
+			w.WriteHeader(500)
+		}
+	}))
+	defer srv.Close()
+
+	cli := http.Client{Breaker: &http.Breaker{Failures: 2, Cooldown: 50 * time.Millisecond}}
+	for i := 0; i < 2; i++ {
+		_, err := cli.Get(c, srv.URL)
+		test.EqualsGo(t, 500, http.ErrorCode(err, 0))
+	}
+	_, err := cli.Get(c, srv.URL)
+	test.Assert(t, errors.Is(err, http.ErrCircuitOpen))
+	test.EqualsGo(t, 503, http.ErrorCode(err, 0))
+	test.EqualsGo(t, int64(2), atomic.LoadInt64(&calls))
+
+	// the probe fails, and the circuit opens again
+	time.Sleep(60 * time.Millisecond)
+	_, err = cli.Get(c, srv.URL)
+	test.EqualsGo(t, 500, http.ErrorCode(err, 0))
+	_, err = cli.Get(c, srv.URL)
+	test.Assert(t, errors.Is(err, http.ErrCircuitOpen))
+
+	// the probe succeeds, and the circuit closes
+	atomic.StoreInt64(&ok, 1)
+	time.Sleep(60 * time.Millisecond)
+	_, err = cli.Get(c, srv.URL)
+	test.NoError(t, err)
+	_, err = cli.Get(c, srv.URL)
+	test.NoError(t, err)
+	test.EqualsGo(t, int64(5), atomic.LoadInt64(&calls))
+}
+
+func TestClientBreakerCancelledProbe(t *testing.T) {
+	c := test.Context(t)
+
+	var calls int64
+	srv := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		if atomic.AddInt64(&calls, 1) <= 2 {
+			w.WriteHeader(500)
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	cli := http.Client{Breaker: &http.Breaker{Failures: 2, Cooldown: 50 * time.Millisecond}}
+	for i := 0; i < 2; i++ {
+		_, err := cli.Get(c, srv.URL)
+		test.EqualsGo(t, 500, http.ErrorCode(err, 0))
+	}
+
+	// the caller of the probe gives up
+	time.Sleep(60 * time.Millisecond)
+	c2, cf := ctx.WithTimeout(c, 20*time.Millisecond)
+	defer cf()
+	_, err := cli.Get(c2, srv.URL)
+	test.Error(t, err)
+	test.Assert(t, !errors.Is(err, http.ErrCircuitOpen))
+
+	// the next request is the probe
+	_, err = cli.Get(c, srv.URL)
+	test.NoError(t, err)
+	test.EqualsGo(t, int64(4), atomic.LoadInt64(&calls))
+}
@@ -3,7 +3,6 @@ package http
 import (
 	"compress/gzip"
 	"context"
-	"encoding/json"
 	"io"
 	"net"
 	"net/http"
@@ -31,6 +30,14 @@ type Server struct {
 
 	OpenAPI *openapi.Service
 
+	apis *sync.Map // patterns registered with RegisterAPI, which can be called by BatchHandler
+
+	// if set, POST requests to RegisterAPI with an Idempotency-Key header run only once, retries get the same response
+	Idempotency IdempotencyStore
+
+	// if set, it's used by RegisterAPI and RegisterStreamingAPI to fill the `auth` fields
+	Auth Authenticator
+
 	// ReadTimeout is the maximum duration for reading the entire
 	// request, including the body. A zero or negative value means
 	// there will be no timeout.
@@ -55,6 +62,22 @@ type Server struct {
 	// let Handlers make decisions on a per-request basis.
 	// A zero or negative value means there will be no timeout.
 	WriteTimeout time.Duration
+
+	// interval between the heartbeats of Server-Sent Events streams, default is 15s
+	SSEHeartbeat time.Duration
+
+	// if set, requests over the limit are rejected with 429 before reaching the handler
+	RateLimit *RateLimiter
+
+	// if positive, Listen() stops accepting new connections while this many are open
+	MaxConnections int
+
+	// tags accepted from the W3C `baggage` header of the requests, the others are ignored
+	Baggage []string
+
+	// if true, the error responses include the cause, with the stack and the tags (see ExposeErrors())
+	// Note: this leaks internals, use it only between trusted services
+	ExposeErrors bool
 }
 
 // Use wraps the server handler with the given middleware.
@@ -83,21 +106,36 @@ func NewServer(c ctx.C) *Server {
 	this := &Server{
 		mux:     http.NewServeMux(),
 		OpenAPI: openapi.NewService("unnamed"),
+		apis:    &sync.Map{},
 	}
 	this.h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		t0 := time.Now()
 		c := r.Context()
 		c = ctx.WithTag(c, "ua", r.UserAgent())
 		c = ctx.WithTag(c, "path", r.URL.Path)
+		c = adopt(c, r, this.Baggage)
+		w.Header().Set(TrackingHeader, ctx.GetTracking(c)) // the one of the caller, not the step of the span below
+		if this.ExposeErrors {
+			c = ExposeErrors(c)
+		}
+
+		_, pattern := this.mux.Handler(r)
+		name := pattern
+		if !strings.Contains(pattern, " ") {
+			name = strings.TrimSpace(r.Method + " " + pattern)
+		}
+		c, cf := ctx.SpanWithKind(c, ctx.SpanServer, name)
 
 		w2 := &response{w, 0}
 		r2 := r.WithContext(c)
-		switch w := w.(type) {
-		case http.Hijacker:
-			// if there is an hijacker, we need to be a bit clever
-			this.mux.ServeHTTP(responseHijacker{w2, w}, r2)
-		default:
-			this.mux.ServeHTTP(w2, r2)
+		if this.rateLimit(c, w2, r2, pattern) {
+			switch w := w.(type) {
+			case http.Hijacker:
+				// if there is an hijacker, we need to be a bit clever
+				this.mux.ServeHTTP(responseHijacker{w2, w}, r2)
+			default:
+				this.mux.ServeHTTP(w2, r2)
+			}
 		}
 
 		path := r2.Pattern
@@ -110,6 +148,11 @@ func NewServer(c ctx.C) *Server {
 			Code:   w2.code,
 			Path:   path,
 		}.observe(time.Since(t0))
+		if w2.code >= 500 {
+			cf(Error{Code: w2.code})
+		} else {
+			cf(nil)
+		}
 	})
 
 	this.mux.HandleFunc("/live", func(w http.ResponseWriter, r *http.Request) {
@@ -150,9 +193,6 @@ func (this *Server) Listen(c ctx.C, addr string) (*net.TCPAddr, error) {
 		ConnContext: func(c context.Context, conn net.Conn) context.Context {
 			return ctx.WithTag(c, "http.remote", conn.RemoteAddr().String())
 		},
-		ConnState: func(conn net.Conn, state http.ConnState) {
-			// TODO(oha): do we need to setup a limiter? if so, this is to know when any hijacker kicks in
-		},
 		ReadTimeout:       this.ReadTimeout,
 		ReadHeaderTimeout: this.ReadHeaderTimeout,
 		WriteTimeout:      this.WriteTimeout,
@@ -173,12 +213,15 @@ func (this *Server) Listen(c ctx.C, addr string) (*net.TCPAddr, error) {
 	go func() {
 		defer shutdown.Hold().Release()
 		// we wrap the listener, so the first call to Accept() will write nil to the error channel
-		l := &listener{
+		var l net.Listener = &listener{
 			Listener: ln,
 			f: func() {
 				ch <- nil
 			},
 		}
+		if this.MaxConnections > 0 {
+			l = &limitListener{Listener: l, sem: make(chan struct{}, this.MaxConnections)}
+		}
 		err := s.Serve(l)
 		if err != nil {
 			log.Warnf(c, "listen(%q) %v", addr, err)
@@ -210,13 +253,17 @@ func (this *listener) Accept() (net.Conn, error) {
 
 // Setup the given streaming function, ignore the method, request body can be nil
 func (this *Server) HandleStream(path string, f StreamFunc) *openapi.PathItem {
-	this.handleStream(path, f)
+	this.handleStream(path, false, true, func(r *http.Request, emit func(c ctx.C, obj any) error) error {
+		return f(r.Context(), r.Body, emit)
+	})
 	return this.makePathItem(path)
 }
 
 // Setup the given request as JSON, and add it to `s.OpenAPI` for the given path as POST, returns the openapi.PathItem
 func (this *Server) HandleRequest(path string, f func(r *Request) (any, error)) *openapi.PathItem {
-	this.handleRequest(path, f)
+	this.handleRequest(path, func(_ ResponseWriter, r *Request) (any, error) {
+		return f(r)
+	})
 	return this.makePathItem(path)
 }
 
@@ -252,20 +299,37 @@ func (this *Server) makePathItem(path string) *openapi.PathItem {
 // a streaming function with an option request body `in` and a function which sends chunks to the client
 type StreamFunc func(c ctx.C, in io.Reader, emit func(c ctx.C, obj any) error) error
 
-func (this *Server) handleStream(path string, f StreamFunc) {
+// if resumable, the ids of Server-Sent Events continue from the Last-Event-ID, otherwise they start from 1
+// GET and HEAD get a 405 unless safe (see api.Safe)
+func (this *Server) handleStream(path string, resumable, safe bool, f func(r *http.Request, emit func(c ctx.C, obj any) error) error) {
 	this.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
 		c := r.Context()
+		if !safe && (r.Method == "GET" || r.Method == "HEAD") {
+			w.Header().Set("Allow", "POST")
+			writeProblem(c, w, NewErrorf(c, 405, "%s not allowed for %s", r.Method, path))
+			return
+		}
 		flusher, ok := w.(http.Flusher)
 		if !ok {
 			log.Errorf(c, "can't cast to http.Flusher: %T", w)
 			w.WriteHeader(500)
 			return
 		}
-		sent := 0
 		if r.Body != nil {
 			defer r.Body.Close()
 		}
-		err := f(c, r.Body, func(c ctx.C, obj any) error {
+		var out streamWriter
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			sse := &sseWriter{w: w, f: flusher}
+			if resumable {
+				sse.id = lastEventID(r)
+			}
+			defer sse.heartbeat(c, this.sseHeartbeat())()
+			out = sse
+		} else {
+			out = &ndjsonWriter{w: w, f: flusher}
+		}
+		err := f(r, func(c ctx.C, obj any) error {
 			if c.Err() != nil {
 				return c.Err()
 			}
@@ -273,56 +337,44 @@ func (this *Server) handleStream(path string, f StreamFunc) {
 			if err != nil {
 				return err
 			}
-			sent += len(json) + 1
-			w.Write(json)
-			w.Write([]byte("\n"))
-			flusher.Flush()
-			return nil
+			return out.write(c, json)
 		})
 		if err == nil {
-			if sent == 0 {
-				w.WriteHeader(204)
-			}
+			out.done(c)
 			return
 		}
 		// error handling
-		if sent == 0 {
+		if !out.started() {
 			log.Warnf(c, "error before streaming: %v", err)
-			switch err := err.(type) {
-			case Error:
-				w.WriteHeader(err.Code)
-				w.Write([]byte(err.Err.Error()))
-			default:
-				w.WriteHeader(500) // TODO is there a way to send a different code?
-			}
+			out.abort(func() {
+				writeProblem(c, w, err)
+			})
 			return
 		}
 		log.Errorf(c, "error while streaming: %v", err)
+		out.fail(c, NewProblem(c, err))
 	})
 }
 
-func (this *Server) handleRequest(path string, f func(r *Request) (any, error)) {
+func (this *Server) sseHeartbeat() time.Duration {
+	if this.SSEHeartbeat > 0 {
+		return this.SSEHeartbeat
+	}
+	return 15 * time.Second
+}
+
+// f can set response headers on w, but must not write to it
+func (this *Server) handleRequest(path string, f func(w ResponseWriter, r *Request) (any, error)) {
 	this.mux.HandleFunc(path, func(w ResponseWriter, r *http.Request) {
 		c := r.Context()
-		out, err := f(r)
+		out, err := f(w, r)
 		if err != nil {
-			tid := ctx.GetTracking(c)
 			log.Warnf(c, "http: %v", err)
-			code := ErrorCode(err, 500)
-			w.WriteHeader(code)
-			if code < 500 {
-				j, _ := json.Marshal(map[string]any{
-					"error":    err.Error(),
-					"tracking": tid,
-				})
-				_, _ = w.Write(j)
-			} else {
-				j, _ := json.Marshal(map[string]any{
-					// NO CLIENT REPORTING FOR INTERNAL ERRORS (due to security reason, we don't want to leak information about internals)
-					"tracking": tid,
-				})
-				_, _ = w.Write(j)
-			}
+			writeProblem(c, w, err)
+			return
+		}
+		if etag := w.Header().Get("ETag"); etag != "" && matchETag(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(304)
 			return
 		}
 		if out == nil {
@@ -361,3 +413,18 @@ func (this *Server) handleRequest(path string, f func(r *Request) (any, error))
 		}
 	})
 }
+
+// check if any of the etags in the If-None-Match header matches etag (weak comparison)
+func matchETag(header string, etag string) bool {
+	if header == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, h := range strings.Split(header, ",") {
+		h = strings.TrimSpace(h)
+		if h == "*" || strings.TrimPrefix(h, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ohait/forego/api"
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/enc"
+)
+
+// trailer set by streaming handlers once the stream is over: "ok" if it completed, or the status code of the error
+const StreamStatus = "X-Stream-Status"
+
+// the last line of a stream which failed after sending some data
+type streamError struct {
+	Error api.Problem `json:"error"`
+}
+
+// the wire format of a stream, negotiated from the Accept header
+type streamWriter interface {
+	write(c ctx.C, json []byte) error
+	started() bool               // true if the status has been sent
+	abort(f func())              // call f which writes the response, only if not started
+	done(c ctx.C)                // the stream completed
+	fail(c ctx.C, p api.Problem) // the stream failed after it started
+}
+
+// NDJSON, a JSON per line
+type ndjsonWriter struct {
+	w    http.ResponseWriter
+	f    http.Flusher
+	sent int
+}
+
+var _ streamWriter = &ndjsonWriter{}
+
+func (this *ndjsonWriter) write(c ctx.C, json []byte) error {
+	if this.sent == 0 {
+		this.w.Header().Set("Content-Type", "application/x-ndjson")
+		this.w.Header().Set("Trailer", StreamStatus)
+	}
+	this.sent += len(json) + 1
+	_, err := this.w.Write(append(json, '\n'))
+	this.f.Flush()
+	return err
+}
+
+func (this *ndjsonWriter) started() bool {
+	return this.sent > 0
+}
+
+func (this *ndjsonWriter) abort(f func()) {
+	f()
+}
+
+func (this *ndjsonWriter) done(c ctx.C) {
+	if this.sent == 0 {
+		this.w.WriteHeader(204)
+	} else {
+		this.w.Header().Set(StreamStatus, "ok")
+	}
+}
+
+func (this *ndjsonWriter) fail(c ctx.C, p api.Problem) {
+	// too late for a status code, we send a last line with the error, and the trailer
+	_, _ = this.w.Write(append(enc.MustMarshalJSON(c, streamError{p}), '\n'))
+	this.w.Header().Set(StreamStatus, strconv.Itoa(p.Status))
+}
+
+// Server-Sent Events, each value is a `data:` event with an incremental id
+type sseWriter struct {
+	m    sync.Mutex
+	w    http.ResponseWriter
+	f    http.Flusher
+	id   int  // last id sent
+	open bool // the status has been sent
+
+	streaming bool // the status was 200, set by start()
+}
+
+var _ streamWriter = &sseWriter{}
+
+// must be called with the lock held
+func (this *sseWriter) start() {
+	if this.open {
+		return
+	}
+	this.open = true
+	this.streaming = true
+	h := this.w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no") // nginx
+	this.w.WriteHeader(200)
+}
+
+// send a comment every given interval once the first event is sent, until the returned function is called
+func (this *sseWriter) heartbeat(c ctx.C, every time.Duration) (stop func()) {
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(every)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-c.Done():
+				return
+			case <-t.C:
+				this.m.Lock()
+				if this.streaming { // before the first event, the status can still be an error
+					_, _ = this.w.Write([]byte(": heartbeat\n\n"))
+					this.f.Flush()
+				}
+				this.m.Unlock()
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+func (this *sseWriter) write(c ctx.C, json []byte) error {
+	this.m.Lock()
+	defer this.m.Unlock()
+	this.start()
+	this.id++
+	_, err := fmt.Fprintf(this.w, "id: %d\ndata: %s\n\n", this.id, json)
+	this.f.Flush()
+	return err
+}
+
+func (this *sseWriter) started() bool {
+	this.m.Lock()
+	defer this.m.Unlock()
+	return this.open
+}
+
+func (this *sseWriter) abort(f func()) {
+	this.m.Lock()
+	defer this.m.Unlock()
+	if this.open {
+		return
+	}
+	this.open = true
+	f()
+}
+
+func (this *sseWriter) done(c ctx.C) {
+	this.m.Lock()
+	defer this.m.Unlock()
+	if !this.open {
+		this.open = true
+		this.w.WriteHeader(204) // also tells EventSource to not reconnect
+		return
+	}
+	_, _ = this.w.Write([]byte("event: end\ndata: {}\n\n"))
+	this.f.Flush()
+}
+
+func (this *sseWriter) fail(c ctx.C, p api.Problem) {
+	this.m.Lock()
+	defer this.m.Unlock()
+	_, _ = fmt.Fprintf(this.w, "event: error\ndata: %s\n\n", enc.MustMarshalJSON(c, p))
+	this.f.Flush()
+}
+
+// the id of the last event received by a reconnecting EventSource, 0 if none
+func lastEventID(r *http.Request) int {
+	id, _ := strconv.Atoi(r.Header.Get("Last-Event-ID"))
+	return max(id, 0)
+}
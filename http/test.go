@@ -32,7 +32,7 @@ func TestAPI[T Doable, UID any](c ctx.C, uid UID, obj T) error {
 		if err != nil {
 			return ctx.NewErrorf(c, "remote: %w", err)
 		}
-		err = op.Do(c)
+		err = api.Do(c, op)
 		if err != nil {
 			return ctx.NewErrorf(c, "remote: %w", err)
 		}
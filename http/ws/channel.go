@@ -3,6 +3,7 @@ package ws
 import (
 	"fmt"
 
+	"github.com/ohait/forego/api"
 	"github.com/ohait/forego/ctx"
 	"github.com/ohait/forego/ctx/log"
 	"github.com/ohait/forego/enc"
@@ -42,31 +43,30 @@ func (this *Channel) onData(c ctx.C, f Frame) error {
 	fn := this.byPath[f.Path]
 	if fn == nil {
 		log.Warnf(c, "ws: unknown path %q for channel %q", f.Path, f.Channel)
-		return this.Conn.Send(c, Frame{
+		return this.Conn.sendError(c, Frame{
 			Channel: f.Channel,
 			Type:    "error",
 			Path:    f.Path,
 			RID:     f.RID,
-			Data:    enc.String("unknown path"),
-		})
+		}, api.NewError(404, "unknown_path", "unknown path %q", f.Path))
 	}
 	if this.Conn != nil && this.Conn.h != nil && this.Conn.h.Trace {
-		log.Debugf(c, "ch[%q].%q(%v)", f.Channel, f.Path, f.Data)
+		log.Debug(c, "ws call", log.F("channel", f.Channel), log.F("path", f.Path), log.F("data", f.Data))
 	}
-	c2, cf := ctx.WithCancel(c)
+	c2, cf := ctx.Span(c, "ws "+f.Path)
 	this.reqCancel.Store(f.RID, cf)
 	go func() {
 		defer this.reqCancel.Delete(f.RID)
 		err := fn(C{C: c2, ch: this, rid: f.RID}, f.Data)
+		ctx.EndSpan(c2, err) // c2 might still be used for replies, don't cancel it
 		if err != nil {
 			log.Warnf(c, "ws: %s error: %v", f.Path, err)
 			if c2.Err() == nil {
-				_ = this.Conn.Send(c, Frame{
+				_ = this.Conn.sendError(c, Frame{
 					Channel: this.ID,
 					Type:    "error",
-					Data:    enc.MustMarshal(c, err.Error()),
 					RID:     f.RID,
-				})
+				}, err)
 			}
 		}
 	}()
@@ -85,6 +85,9 @@ type Frame struct {
 	RID string `json:"rid,omitempty"` // request id for matching request and response
 
 	Data enc.Node `json:"data,omitempty"`
+
+	// of failures, the detail (or the title) of the problem in Data, for clients which expect a string (see Handler.StringErrors)
+	Message string `json:"message,omitempty"`
 }
 
 type C struct {
@@ -105,6 +108,14 @@ func (c C) Reply(path string, obj any) error {
 	})
 }
 
+// returns the identity of the connection (see Handler.Auth), or nil
+func (c C) UID() enc.Node {
+	if c.ch == nil || c.ch.Conn == nil {
+		return nil
+	}
+	return c.ch.Conn.UID
+}
+
 func (c C) IsClosed() bool {
 	return c.ch == nil || c.ch.Conn == nil
 }
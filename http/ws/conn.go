@@ -1,11 +1,14 @@
 package ws
 
 import (
+	"cmp"
 	"io"
 
+	"github.com/ohait/forego/api"
 	"github.com/ohait/forego/ctx"
 	"github.com/ohait/forego/ctx/log"
 	"github.com/ohait/forego/enc"
+	fhttp "github.com/ohait/forego/http"
 	"github.com/ohait/forego/shutdown"
 	"github.com/ohait/forego/utils/sync"
 )
@@ -14,6 +17,9 @@ type Conn struct {
 	h      *Handler
 	ws     impl
 	byChan sync.Map[string, *Channel]
+
+	// the identity returned by Handler.Auth during the handshake, if any
+	UID enc.Node
 }
 
 func (this *Conn) Close(c ctx.C, reason int) error {
@@ -102,13 +108,12 @@ func (this *Conn) onData(c ctx.C, f Frame) error {
 			return ch.cancelRequest(c, f.RID)
 		} else {
 			log.Warnf(c, "ws: unknown channel %q for cancelling request %q", f.Channel, f.RID)
-			return this.Send(c, Frame{
+			return this.sendError(c, Frame{
 				Channel: f.Channel,
 				Type:    "error",
 				Path:    f.Path,
 				RID:     f.RID,
-				Data:    enc.String("unknown channel"),
-			})
+			}, api.NewError(404, "unknown_channel", "unknown channel %q", f.Channel))
 		}
 	case "new", "open":
 		if fn := this.h.byPath.Get(f.Path); fn != nil {
@@ -120,13 +125,12 @@ func (this *Conn) onData(c ctx.C, f Frame) error {
 			return ch.onData(c, f)
 		}
 		log.Warnf(c, "ws: unknown channel %q", f.Channel)
-		return this.Send(c, Frame{
+		return this.sendError(c, Frame{
 			Channel: f.Channel,
 			Type:    "error",
 			Path:    f.Path,
 			RID:     f.RID,
-			Data:    enc.String("unknown channel"),
-		})
+		}, api.NewError(404, "unknown_channel", "unknown channel %q", f.Channel))
 	}
 }
 
@@ -142,3 +146,16 @@ func (this *Conn) SendOrClose(c ctx.C, f Frame) error {
 func (this *Conn) Send(c ctx.C, f Frame) error {
 	return this.ws.Write(c, enc.MustMarshal(c, f))
 }
+
+// send f with the error as an RFC 7807 problem in Data (same as the http server would send it), and its Message
+// if Handler.StringErrors, Data is the Message instead
+func (this *Conn) sendError(c ctx.C, f Frame, err error) error {
+	p := fhttp.NewProblem(c, err)
+	f.Message = cmp.Or(p.Detail, p.Title)
+	if this.h != nil && this.h.StringErrors {
+		f.Data = enc.String(f.Message)
+	} else {
+		f.Data = enc.MustMarshal(c, p)
+	}
+	return this.Send(c, f)
+}
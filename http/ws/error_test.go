@@ -0,0 +1,37 @@
+package ws
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ohait/forego/ctx"
+	"github.com/ohait/forego/test"
+)
+
+type Failing struct{}
+
+func (this *Failing) Init(c C) error {
+	return nil
+}
+
+func (this *Failing) Fail(c C) error {
+	return ctx.NewErrorf(c, "internal failure")
+}
+
+func TestExposeErrors(t *testing.T) {
+	c := test.Context(t)
+	c, cf := ctx.WithCancel(c)
+	defer cf(nil)
+	h := Handler{ExposeErrors: true}
+	h.MustRegister(c, &Failing{})
+
+	cli := h.NewTest(c)
+	ch, err := cli.Open(c, "failing", "001", nil, func(c ctx.C, f Frame) error { return nil })
+	test.NoError(t, err)
+	err = ch.Request(c, "fail", nil, nil)
+	test.Error(t, err)
+	var re ctx.RemoteError
+	test.Assert(t, errors.As(err, &re))
+	test.EqualsStr(t, "internal failure", re.Message)
+	test.Contains(t, re.Stack[0], "ws/error_test.go")
+}
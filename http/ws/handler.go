@@ -7,6 +7,7 @@ import (
 	"github.com/ohait/forego/ctx"
 	"github.com/ohait/forego/ctx/log"
 	"github.com/ohait/forego/enc"
+	fhttp "github.com/ohait/forego/http"
 	"github.com/ohait/forego/shutdown"
 	"github.com/ohait/forego/utils/sync"
 	"golang.org/x/net/websocket"
@@ -19,9 +20,26 @@ type Handler struct {
 	// Called when shutdown.Started()
 	OnShutdown func(c ctx.C, conn *Conn)
 
+	// if set, it's called during the handshake, and the result is available as `ws.C.UID()`
+	// Note: a failed handshake is always reported as 403 by golang.org/x/net/websocket
+	Auth fhttp.Authenticator
+
+	// reject the handshake if Auth returns no identity
+	AuthRequired bool
+
+	// if true, the error frames include the cause, with the stack and the tags (see http.ExposeErrors())
+	ExposeErrors bool
+
+	// if true, the data of error frames is the message string, as before they carried an api.Problem
+	// Deprecated: for clients which are not migrated yet, they should read `message` instead
+	StringErrors bool
+
 	byPath sync.Map[string, func(ctx.C, *Conn, Frame) error]
 }
 
+// the identity returned by Auth, from the handshake to the connection
+var uidKey = &ctx.Key[enc.Node]{Name: "ws.uid"}
+
 // return a websocket.Server which can be used as an http.Handler
 // Note: it sets a default Handshake handler which accept any requests,
 // you might need to change it if you need to control the `Origin` header.
@@ -29,18 +47,23 @@ func (this *Handler) Server() websocket.Server {
 	x := websocket.Server{
 		Handler: websocket.Handler(func(conn *websocket.Conn) {
 			c := conn.Request().Context()
-			c, cf := ctx.Span(c, "ws")
+			c, cf := ctx.SpanWithKind(c, ctx.SpanServer, "ws")
 			defer cf(nil)
+			if this.ExposeErrors {
+				c = fhttp.ExposeErrors(c)
+			}
 
 			defer shutdown.Hold().Release()
 
 			// defer metrics.WS{Path: path}.Start().End(c)
+			uid, _ := uidKey.Get(c)
 			ws := Conn{
 				h: this,
 				ws: &wsImpl{
 					conn:  conn,
 					trace: this.Trace,
 				},
+				UID: uid,
 			}
 			defer ws.Close(c, 1000)
 			err := ws.Loop(c)
@@ -53,12 +76,37 @@ func (this *Handler) Server() websocket.Server {
 			if err == nil && config.Origin == nil {
 				return fmt.Errorf("null origin")
 			}
-			return err
+			if err != nil {
+				return err
+			}
+			return this.authenticate(req)
 		},
 	}
 	return x
 }
 
+func (this *Handler) authenticate(req *http.Request) error {
+	if this.Auth == nil {
+		return nil
+	}
+	c := req.Context()
+	uid, err := this.Auth.Authenticate(c, req)
+	if err != nil {
+		log.Infof(c, "ws: authentication failed: %v", err)
+		return err
+	}
+	if uid == nil || uid == (enc.Nil{}) {
+		if this.AuthRequired {
+			log.Infof(c, "ws: authentication required")
+			return ctx.NewErrorf(c, "auth required")
+		}
+		return nil
+	}
+	// the handshake can't return a new request, but the connection uses the same one
+	*req = *req.WithContext(uidKey.With(c, uid))
+	return nil
+}
+
 // MustRegister is like Register but panics on error.
 func (this *Handler) MustRegister(c ctx.C, obj any) *Handler {
 	err := this.Register(c, obj)
@@ -157,13 +205,12 @@ func (this *Handler) Register(c ctx.C, obj any) error {
 			if c.Err() == nil {
 				log.Warnf(c, "build %q: %v", b.name, err)
 			}
-			return conn.Send(c, Frame{
+			return conn.sendError(c, Frame{
 				Channel: f.Channel,
 				Path:    f.Path,
 				Type:    "return",
-				Data:    enc.MustMarshal(c, err),
 				RID:     f.RID,
-			})
+			}, err)
 		}
 		log.Debugf(c, "new %+v", obj)
 		return nil
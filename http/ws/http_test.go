@@ -2,6 +2,7 @@ package ws_test
 
 import (
 	"io"
+	gohttp "net/http"
 	"testing"
 
 	"github.com/ohait/forego/enc"
@@ -66,3 +67,50 @@ func (this *Echo) Init(c ws.C, in string) error {
 	defer c.Close()
 	return c.Reply("echo", in)
 }
+
+type Whoami struct{}
+
+func (this *Whoami) Init(c ws.C) error {
+	defer c.Close()
+	return c.Reply("uid", c.UID())
+}
+
+func TestHttpAuth(t *testing.T) {
+	c := test.Context(t)
+	s := http.NewServer(c)
+	addr, err := s.Listen(c, "127.0.0.1:0")
+	test.NoError(t, err)
+
+	jwt := http.JWT{Secret: []byte("secret")}
+	h := &ws.Handler{
+		Auth:         jwt,
+		AuthRequired: true,
+	}
+	h.MustRegister(c, &Whoami{})
+	s.Mux().Handle("/ws", h.Server())
+
+	conf, err := websocket.NewConfig("ws://"+addr.String()+"/ws", "http://"+addr.String()+"/")
+	test.NoError(t, err)
+	_, err = websocket.DialConfig(conf)
+	test.Error(t, err) // no token
+
+	tok, err := jwt.Sign(c, enc.Map{"sub": enc.String("alice")})
+	test.NoError(t, err)
+	conf.Header = gohttp.Header{"Authorization": {"Bearer " + tok}}
+	conn, err := websocket.DialConfig(conf)
+	test.NoError(t, err)
+
+	_, err = conn.Write(enc.MustMarshalJSON(c, ws.Frame{
+		Channel: "c0",
+		Path:    "whoami",
+		Type:    "open",
+	}))
+	test.NoError(t, err)
+
+	buf := make([]byte, 1024)
+	ct, err := conn.Read(buf)
+	test.NoError(t, err)
+	var f ws.Frame
+	test.NoError(t, enc.UnmarshalJSON(c, buf[0:ct], &f))
+	test.EqualsJSON(t, "alice", f.Data)
+}
@@ -46,13 +46,12 @@ func (this builder) build(c C, req enc.Node) (any, error) {
 		c.ch.byPath[method.name] = func(c C, req enc.Node) error {
 			err := method.call(c, v, req)
 			if err != nil {
-				_ = c.ch.Conn.Send(c, Frame{
+				_ = c.ch.Conn.sendError(c, Frame{
 					Channel: c.ch.ID,
 					Path:    method.name,
 					Type:    "return",
-					Data:    enc.MustMarshal(c, err),
 					RID:     c.rid,
-				})
+				}, err)
 				log.Infof(c, "ws[%s|%s]: error: %v", c.ch.ID, method.name, err)
 				return nil
 			}
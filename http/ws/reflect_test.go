@@ -127,3 +127,27 @@ func TestReflect(t *testing.T) {
 	test.EqualsGo(t, int32(1), atomic.LoadInt32(&counterClosed))
 	t.Logf("EXIT")
 }
+
+func TestErrorFrames(t *testing.T) {
+	c := test.Context(t)
+	for _, legacy := range []bool{false, true} {
+		send := make(chan chanMsg, 10)
+		conn := Conn{
+			h:  &Handler{StringErrors: legacy},
+			ws: &chanImpl{Send: send},
+		}
+		test.NoError(t, conn.onData(c, Frame{
+			Channel: "404",
+			Path:    "get",
+		}))
+		var f Frame
+		enc.MustUnmarshal(c, (<-send).Data, &f)
+		test.EqualsGo(t, "error", f.Type)
+		test.EqualsGo(t, `unknown channel "404"`, f.Message)
+		if legacy {
+			test.EqualsJSON(t, `unknown channel "404"`, f.Data)
+		} else {
+			test.ContainsJSON(t, f.Data, `"code":"unknown_channel"`)
+		}
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"io"
 
 	"github.com/google/uuid"
+	"github.com/ohait/forego/api"
 	"github.com/ohait/forego/ctx"
 	"github.com/ohait/forego/ctx/log"
 	"github.com/ohait/forego/enc"
+	fhttp "github.com/ohait/forego/http"
 )
 
 type TestClient struct {
@@ -66,6 +68,9 @@ func (this *Handler) NewTest(c ctx.C) *TestClient {
 		h:  this,
 		ws: ws,
 	}
+	if this.ExposeErrors {
+		c = fhttp.ExposeErrors(c)
+	}
 	go conn.Loop(c) // nolint
 
 	return &TestClient{
@@ -109,7 +114,12 @@ func (this *TestChannel) onData(c ctx.C, f Frame) error {
 		}
 		var err error
 		if f.Data != nil {
-			err = ctx.NewErrorf(c, "remote: %s", f.Data)
+			var p api.Problem
+			if enc.Unmarshal(c, f.Data, &p) == nil && p.Status != 0 {
+				err = ctx.NewErrorf(c, "remote: %w", p.AsError())
+			} else {
+				err = ctx.NewErrorf(c, "remote: %s", f.Data)
+			}
 		}
 		ech <- err
 		return nil
@@ -42,7 +42,7 @@ func (this *wsImpl) Write(c ctx.C, n enc.Node) error {
 	this.m.Lock()
 	defer this.m.Unlock()
 	if this.trace {
-		log.Debugf(c, "ws.write: %s", j)
+		log.Debug(c, "ws.write", log.F("data", ctx.JSON(j)))
 	}
 	return this.write(c, j)
 }
@@ -120,7 +120,7 @@ func (this *wsImpl) read(c ctx.C) ([]byte, error) {
 		return nil, ctx.WrapError(c, err)
 	}
 	if this.trace {
-		log.Debugf(c, "ws recv: %s", data)
+		log.Debug(c, "ws recv", log.F("data", ctx.JSON(data)))
 	}
 	return data, nil
 }
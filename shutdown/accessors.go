@@ -7,8 +7,9 @@ import (
 	"github.com/ohait/forego/ctx"
 )
 
-// Use to signal that an operation can't be executed because the system is shutting down
-var Err = errors.New("shutdown")
+// Use to signal that an operation can't be executed because the system is shutting down,
+// classified as ctx.ErrUnavailable and ctx.ErrRetryable (another instance may serve it)
+var Err = ctx.Classify(errors.New("shutdown"), ctx.ErrUnavailable, ctx.ErrRetryable)
 
 type ReleaseFn func()
 
@@ -33,7 +34,7 @@ func Started5Sec() <-chan struct{} {
 	return shutdowner.started5Sec()
 }
 
-// returns a channel that will close when the shutdown has completed
+// returns a channel that will close when the shutdown has completed, after flushing the log sinks (see `log.Sink`)
 func Done() <-chan struct{} {
 	return shutdowner.done()
 }
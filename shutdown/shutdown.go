@@ -61,11 +61,12 @@ func (this *shutter) started5Sec() <-chan struct{} {
 	return this.ch5
 }
 
-// returns a channel that will close when the shutdown has completed
+// returns a channel that will close when the shutdown has completed, and the log sinks have been flushed
 func (this *shutter) done() <-chan struct{} {
 	ch := make(chan struct{})
 	go func() {
 		this.wg.Wait()
+		log.Flush()
 		close(ch)
 	}()
 	return ch
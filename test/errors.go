@@ -16,7 +16,7 @@ func NoError(t testing.TB, err error) {
 	} else {
 		var cErr ctx.Error
 		if errors.As(err, &cErr) {
-			Fail(t, "%v\n\t%s", err, strings.Join(cErr.Stack, "\n\t"))
+			Fail(t, "%v\n\t%s", err, strings.Join(cErr.Stack.Frames(), "\n\t"))
 		} else {
 			Fail(t, "%T %v", err, err)
 		}
@@ -41,7 +41,7 @@ func ErrorIs(t testing.TB, err error, target error) {
 	} else {
 		var cErr ctx.Error
 		if errors.As(err, &cErr) {
-			Fail(t, "%v\n\t%s", err, strings.Join(cErr.Stack, "\n\t"))
+			Fail(t, "%v\n\t%s", err, strings.Join(cErr.Stack.Frames(), "\n\t"))
 		} else {
 			Fail(t, "%T %v", err, err)
 		}
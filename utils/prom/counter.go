@@ -61,3 +61,20 @@ func (this *Counter) Print(w io.Writer) error {
 		}
 	})
 }
+
+// Counter.Print() doesn't match Metric, use this instead of Register(), the Name defaults to name
+func RegisterCounter(name string, m *Counter) *Counter {
+	if m.Name == "" {
+		m.Name = name
+	}
+	Register(name, counterMetric{m})
+	return m
+}
+
+type counterMetric struct {
+	*Counter
+}
+
+func (this counterMetric) Print(_ string, w io.Writer) error {
+	return this.Counter.Print(w)
+}
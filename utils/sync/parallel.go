@@ -12,14 +12,14 @@ func Go[T any](c ctx.C, items []T, max int, f func(c ctx.C, item T) error) error
 	var mu sync.Mutex
 	var firstErr error
 
-	wg.Add(len(items))
 	for _, item := range items {
 		select {
 		case limiter <- struct{}{}:
 		case <-c.Done():
-			wg.Done()
+			wg.Wait() // the ones already started
 			return c.Err()
 		}
+		wg.Add(1)
 		go func(item T) {
 			defer wg.Done()
 			defer func() { <-limiter }()
@@ -1,6 +1,7 @@
 package sync_test
 
 import (
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -20,3 +21,19 @@ func TestMulti(t *testing.T) {
 	test.Assert(t, elapsed >= 250*time.Millisecond)
 	test.Assert(t, elapsed < 400*time.Millisecond)
 }
+
+func TestMultiCancel(t *testing.T) {
+	c := test.Context(t)
+	c, cf := ctx.WithCancel(c)
+	var done atomic.Int32
+	err := sync.Go(c, []int{0, 1, 2, 3}, 2, func(c ctx.C, i int) error {
+		if i == 1 {
+			cf(nil)
+		}
+		time.Sleep(50 * time.Millisecond)
+		done.Add(1)
+		return nil
+	})
+	test.Error(t, err)
+	test.EqualsGo(t, int32(2), done.Load()) // the started ones are waited for
+}